@@ -17,41 +17,271 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"github.com/SENERGY-Platform/process-history-cleanup/pkg"
+	"github.com/SENERGY-Platform/process-history-cleanup/pkg/api"
 	"github.com/SENERGY-Platform/process-history-cleanup/pkg/configuration"
+	"github.com/SENERGY-Platform/process-history-cleanup/pkg/metrics"
+	"github.com/SENERGY-Platform/process-history-cleanup/pkg/scheduler"
 	"log"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
 	"time"
 )
 
-func main() {
-	time.Sleep(5 * time.Second) //wait for routing tables in cluster
+// exitCodeInterrupted is used if SIGINT or SIGTERM stops a running cleanup
+// a signal received while waiting for the next scheduled run results in exit code 0
+const exitCodeInterrupted = 130
 
-	confLocation := flag.String("config", "config.json", "configuration file")
+func main() {
+	confLocation := flag.String("config", "config.json", "configuration file (.json, .yaml, .yml or .toml)")
+	dryRun := flag.Bool("dry-run", false, "only report which process instance histories would be removed")
 	flag.Parse()
 
 	config, err := configuration.Load(*confLocation)
 	if err != nil {
-		log.Fatal("ERROR: unable to load config ", err)
+		log.Fatal("ERROR: unable to load config:\n", err)
 	}
-
-	err = pkg.RunCleanup(config)
+	initial, err := newPlan(config, *dryRun)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	if config.Interval != "" && config.Interval != "-" {
-		interval, err := time.ParseDuration(config.Interval)
+	//invalid configs fail before the startup delay
+	ctx := shutdownContext()
+
+	//SIGHUP is handled before the startup delay, because its default action stops the process
+	//reloads that arrive before the first scheduled wait are applied after the startup run
+	var configs <-chan configuration.Config
+	if initial.schedule == nil {
+		signal.Ignore(syscall.SIGHUP) //a single run has no config to reload
+	} else {
+		configs, err = configuration.Watch(ctx, *confLocation)
 		if err != nil {
-			log.Fatal(err)
+			log.Println("WARNING: unable to watch config file, reload only on restart", err)
+			signal.Ignore(syscall.SIGHUP)
+		}
+	}
+
+	err = sleep(ctx, 5*time.Second) //wait for routing tables in cluster
+	if err != nil {
+		return
+	}
+
+	m := metrics.New()
+	updateApi := api.Start(initial.config, m)
+
+	engines := &engineCache{metrics: m}
+	defer engines.close()
+
+	//a run that is stopped by a closing maintenance window is continued in the next window
+	//returns errShutdown if ctx is done while no cleanup is running
+	runCleanup := func(current *plan) (err error) {
+		if ctx.Err() != nil {
+			return errShutdown
 		}
-		ticker := time.NewTicker(interval)
-		for range ticker.C {
-			err = pkg.RunCleanup(config)
+		engine, err := engines.get(current)
+		if err != nil {
+			return err
+		}
+		err = pkg.RunCleanupWithEngine(ctx, current.config, engine, m)
+		for errors.Is(err, pkg.ErrOutsideMaintenanceWindow) {
+			next := current.windows.NextOpen(time.Now())
+			log.Println("continue cleanup in next maintenance window at", next.String())
+			err = sleepUntil(ctx, next, m, nil)
 			if err != nil {
-				log.Println(err)
+				return errShutdown
 			}
+			err = pkg.RunCleanupWithEngine(ctx, current.config, engine, m)
+		}
+		if errors.Is(err, context.Canceled) {
+			log.Println("cleanup interrupted by shutdown")
+			os.Exit(exitCodeInterrupted)
+		}
+		return err
+	}
+
+	if !initial.config.SkipStartupRun {
+		err = runCleanup(initial)
+		if errors.Is(err, errShutdown) {
+			log.Println("shutdown")
+			return
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if initial.schedule == nil {
+		return
+	}
+
+	//reloaded configs replace the plan between runs; a running cleanup keeps the plan it was started with
+	current := atomic.Pointer[plan]{}
+	current.Store(initial)
+	reloaded := make(chan struct{}, 1)
+	go watchConfig(configs, *dryRun, func(next *plan) {
+		current.Store(next)
+		updateApi(next.config)
+		select {
+		case reloaded <- struct{}{}:
+		default:
+		}
+	})
+
+	last := time.Now()
+	for {
+		next := scheduler.NextAfter(current.Load().schedule, last, time.Now())
+		log.Println("next cleanup at", next.String())
+		err = sleepUntil(ctx, next, m, reloaded)
+		if errors.Is(err, errReloaded) {
+			continue
+		}
+		if err != nil {
+			log.Println("shutdown")
+			return
+		}
+		last = next
+		err = runCleanup(current.Load())
+		if errors.Is(err, errShutdown) {
+			log.Println("shutdown")
+			return
+		}
+		if err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+// plan is a config with its parsed schedule and maintenance windows
+type plan struct {
+	config   configuration.Config
+	schedule scheduler.Schedule
+	windows  *scheduler.Windows
+}
+
+func newPlan(config configuration.Config, dryRun bool) (*plan, error) {
+	if dryRun {
+		config.DryRun = true
+	}
+	schedule, err := scheduler.FromConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	windows, err := scheduler.WindowsFromConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	return &plan{config: config, schedule: schedule, windows: windows}, nil
+}
+
+// engineCache keeps the engine of the current plan between runs, so that throttle state, auth tokens
+// and connections are reused; the engine is created again when a reload replaces the plan
+type engineCache struct {
+	metrics *metrics.Metrics
+	plan    *plan
+	engine  pkg.Camunda
+	release func()
+}
+
+func (this *engineCache) get(current *plan) (pkg.Camunda, error) {
+	if this.plan == current {
+		return this.engine, nil
+	}
+	this.close()
+	engine, release, err := pkg.NewEngine(current.config, this.metrics)
+	if err != nil {
+		return nil, err
+	}
+	this.plan = current
+	this.engine = engine
+	this.release = release
+	return engine, nil
+}
+
+func (this *engineCache) close() {
+	if this.release != nil {
+		this.release()
+	}
+	this.plan = nil
+	this.engine = nil
+	this.release = nil
+}
+
+// watchConfig passes every reloaded config that results in a valid plan with a schedule to apply
+// the api port and the startup run are only read on startup; configs may be nil
+func watchConfig(configs <-chan configuration.Config, dryRun bool, apply func(next *plan)) {
+	if configs == nil {
+		return
+	}
+	for config := range configs {
+		next, err := newPlan(config, dryRun)
+		if err != nil {
+			log.Println("ERROR: unable to apply reloaded config, keep current config:", err)
+			continue
+		}
+		if next.schedule == nil {
+			log.Println("ERROR: reloaded config has neither interval nor schedule, keep current config")
+			continue
 		}
+		apply(next)
+		log.Println("applied reloaded config")
 	}
+}
 
+// shutdownContext returns a context that is canceled on SIGINT or SIGTERM
+// a second signal exits immediately
+func shutdownContext() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		sig := <-signals
+		log.Println("received", sig.String()+", stop after in-flight deletions")
+		cancel()
+		sig = <-signals
+		log.Println("received", sig.String(), "again, exit immediately")
+		os.Exit(exitCodeInterrupted)
+	}()
+	return ctx
+}
+
+var errReloaded = errors.New("config reloaded")
+var errShutdown = errors.New("shutdown while waiting")
+
+// sleepUntil keeps sending heartbeats while waiting, so that long waits are not reported as unhealthy
+// returns ctx.Err() if ctx is done before t and errReloaded if reloaded receives first; reloaded may be nil
+func sleepUntil(ctx context.Context, t time.Time, m *metrics.Metrics, reloaded <-chan struct{}) error {
+	for {
+		m.Heartbeat()
+		wait := time.Until(t)
+		if wait <= 0 {
+			return nil
+		}
+		timer := time.NewTimer(min(wait, time.Minute))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-reloaded:
+			timer.Stop()
+			return errReloaded
+		case <-timer.C:
+		}
+	}
+}
+
+func sleep(ctx context.Context, duration time.Duration) error {
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
 }
@@ -0,0 +1,54 @@
+/*
+ * Copyright 2020 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"github.com/SENERGY-Platform/process-history-cleanup/pkg/configuration"
+	"github.com/SENERGY-Platform/process-history-cleanup/pkg/metrics"
+	"log"
+	"net/http"
+)
+
+// Start serves the api in the background
+// does nothing if config.ApiPort is empty or "-"
+// the returned function applies a reloaded config to the health checks; the port is not changed by reloads
+func Start(config configuration.Config, metrics *metrics.Metrics) (update func(config configuration.Config)) {
+	if config.ApiPort == "" || config.ApiPort == "-" {
+		return func(configuration.Config) {}
+	}
+	router, update := NewRouter(config, metrics)
+	server := &http.Server{Addr: ":" + config.ApiPort, Handler: router}
+	go func() {
+		log.Println("listening on", server.Addr)
+		err := server.ListenAndServe()
+		if err != nil && err != http.ErrServerClosed {
+			log.Fatal("ERROR: api server error", err)
+		}
+	}()
+	return update
+}
+
+// NewRouter returns the handler of the api endpoints without starting a server
+// the returned function applies a reloaded config to the health checks
+func NewRouter(config configuration.Config, metrics *metrics.Metrics) (router http.Handler, update func(config configuration.Config)) {
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", metrics.Handler())
+	health := newHealth(config, metrics)
+	mux.HandleFunc("GET /health", health.Health)
+	mux.HandleFunc("GET /ready", health.Ready)
+	return mux, health.update
+}
@@ -0,0 +1,125 @@
+/*
+ * Copyright 2020 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"fmt"
+	"github.com/SENERGY-Platform/process-history-cleanup/pkg"
+	"github.com/SENERGY-Platform/process-history-cleanup/pkg/camunda"
+	"github.com/SENERGY-Platform/process-history-cleanup/pkg/configuration"
+	"github.com/SENERGY-Platform/process-history-cleanup/pkg/metrics"
+	"github.com/SENERGY-Platform/process-history-cleanup/pkg/scheduler"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// the scheduler sends a heartbeat on every tick; cleanup runs send one on every engine request, listed batch and deletion
+// missing two ticks or two engine requests in a row is considered a hung process, also while a cleanup run is in progress
+// the grace period is limited to one scheduled period
+const healthGracePeriod = time.Minute
+
+type health struct {
+	config   configuration.Config
+	metrics  *metrics.Metrics
+	schedule scheduler.Schedule //nil if the heartbeat is not checked
+	mux      sync.Mutex
+	engine   *readinessEngine //nil until the next readiness check
+}
+
+// readinessEngine is closed after the last readiness check that uses it has finished
+type readinessEngine struct {
+	engine pkg.Camunda
+	close  func()
+	inUse  sync.WaitGroup
+}
+
+func newHealth(config configuration.Config, metrics *metrics.Metrics) *health {
+	result := &health{metrics: metrics}
+	result.update(config)
+	return result
+}
+
+// update replaces the config of a reload; the engine is created again on the next readiness check
+// the previous engine is closed in the background once its in-flight readiness checks are finished
+func (this *health) update(config configuration.Config) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	this.config = config
+	this.schedule = nil
+	schedule, err := scheduler.FromConfig(config)
+	if err == nil {
+		this.schedule = schedule
+	}
+	if previous := this.engine; previous != nil {
+		go func() {
+			previous.inUse.Wait()
+			previous.close()
+		}()
+	}
+	this.engine = nil
+}
+
+// Health fails if the last heartbeat is older than two scheduled periods or two engine requests
+// engine requests are limited by engine_request_timeout, so a running cleanup sends heartbeats regularly
+func (this *health) Health(writer http.ResponseWriter, request *http.Request) {
+	this.mux.Lock()
+	schedule := this.schedule
+	config := this.config
+	this.mux.Unlock()
+	if schedule != nil {
+		period := scheduler.Period(schedule, time.Now())
+		staleAfter := 2*max(period, camunda.MaxRequestInterval(config)) + min(period, healthGracePeriod)
+		since := time.Since(this.metrics.LastActivity())
+		if since > staleAfter {
+			http.Error(writer, fmt.Sprintf("last activity %v ago", since.Round(time.Second)), http.StatusServiceUnavailable)
+			return
+		}
+	}
+	writer.WriteHeader(http.StatusOK)
+}
+
+// Ready fails if the engine can not be reached
+func (this *health) Ready(writer http.ResponseWriter, request *http.Request) {
+	engine, err := this.getEngine()
+	if err == nil {
+		_, err = engine.engine.ListHistoryCount(request.Context(), true)
+		engine.inUse.Done()
+	}
+	if err != nil {
+		log.Println("WARNING: engine not ready", err)
+		http.Error(writer, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	writer.WriteHeader(http.StatusOK)
+}
+
+// getEngine marks the returned engine as in use; the caller has to call inUse.Done()
+func (this *health) getEngine() (*readinessEngine, error) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	if this.engine == nil {
+		engine, close, err := pkg.NewEngine(this.config, nil)
+		if err != nil {
+			return nil, err
+		}
+		this.engine = &readinessEngine{engine: engine, close: close}
+	}
+	this.engine.inUse.Add(1)
+	return this.engine, nil
+}
@@ -0,0 +1,42 @@
+/*
+ * Copyright 2020 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pkg
+
+import (
+	"context"
+	"github.com/SENERGY-Platform/process-history-cleanup/pkg/archive"
+	"github.com/SENERGY-Platform/process-history-cleanup/pkg/camunda"
+)
+
+// archiveInstances collects the activity and variable history of the instances and writes it to the archive sink
+// the listed instances already contain every field of the historic process instance resource
+func (this *cleaner) archiveInstances(ctx context.Context, instances camunda.HistoricProcessInstances) (err error) {
+	records := []archive.Record{}
+	for _, instance := range instances {
+		record := archive.Record{Instance: instance}
+		record.ActivityInstances, err = this.engine.ListHistoricActivityInstances(ctx, instance.Id)
+		if err != nil {
+			return err
+		}
+		record.VariableInstances, err = this.engine.ListHistoricVariableInstances(ctx, instance.Id)
+		if err != nil {
+			return err
+		}
+		records = append(records, record)
+	}
+	return this.archive.Write(ctx, records)
+}
@@ -0,0 +1,66 @@
+/*
+ * Copyright 2020 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package archive
+
+import (
+	"context"
+	"fmt"
+	"github.com/SENERGY-Platform/process-history-cleanup/pkg/camunda"
+	"github.com/SENERGY-Platform/process-history-cleanup/pkg/configuration"
+)
+
+// Record is the archived form of one historic process instance
+type Record struct {
+	Instance          camunda.HistoricProcessInstance    `json:"instance"`
+	ActivityInstances []camunda.HistoricActivityInstance `json:"activityInstances"`
+	VariableInstances []camunda.HistoricVariableInstance `json:"variableInstances"`
+}
+
+// Sink stores records before their process instances are deleted
+// Write may only return nil if the records are persisted; it stops with ctx.Err() if ctx is done
+type Sink interface {
+	Write(ctx context.Context, records []Record) error
+	Close() error
+}
+
+const (
+	SinkNone = ""
+	SinkFile = configuration.ArchiveSinkFile
+	SinkS3   = configuration.ArchiveSinkS3
+)
+
+// New returns nil if archiving is disabled
+func New(config configuration.Config) (Sink, error) {
+	switch config.ArchiveSink {
+	case SinkNone, "-":
+		return nil, nil
+	case SinkFile:
+		return NewFileSink(config.ArchiveDir, config.ArchiveMaxFileSize, config.ArchiveGzip)
+	case SinkS3:
+		return NewS3Sink(S3Config{
+			Endpoint:  config.ArchiveS3Endpoint,
+			Bucket:    config.ArchiveS3Bucket,
+			Region:    config.ArchiveS3Region,
+			AccessKey: config.ArchiveS3AccessKey,
+			SecretKey: config.ArchiveS3SecretKey,
+			Prefix:    config.ArchiveS3Prefix,
+			Gzip:      config.ArchiveGzip,
+		})
+	default:
+		return nil, fmt.Errorf("unknown archive_sink %v", config.ArchiveSink)
+	}
+}
@@ -0,0 +1,122 @@
+/*
+ * Copyright 2020 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package archive
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// FileSink writes records as newline delimited json into dir
+// a new file is started for every sink and whenever the current file exceeds maxFileSize bytes (0 disables rotation)
+// with gzip enabled, every Write appends a complete gzip member, so files stay readable if the service stops unexpectedly
+type FileSink struct {
+	dir         string
+	maxFileSize int64
+	gzip        bool
+	file        *os.File
+	size        int64
+	sequence    int
+}
+
+func NewFileSink(dir string, maxFileSize int64, useGzip bool) (*FileSink, error) {
+	if dir == "" {
+		return nil, errors.New("expect archive_dir for file archive")
+	}
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+	return &FileSink{dir: dir, maxFileSize: maxFileSize, gzip: useGzip}, nil
+}
+
+// local writes are not interrupted, ctx is only checked before writing
+func (this *FileSink) Write(ctx context.Context, records []Record) (err error) {
+	if len(records) == 0 {
+		return nil
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	buf := &bytes.Buffer{}
+	if this.gzip {
+		writer := gzip.NewWriter(buf)
+		err = encodeNdjson(writer, records)
+		if err != nil {
+			return err
+		}
+		err = writer.Close()
+		if err != nil {
+			return err
+		}
+	} else {
+		err = encodeNdjson(buf, records)
+		if err != nil {
+			return err
+		}
+	}
+	if this.file == nil || (this.maxFileSize > 0 && this.size > 0 && this.size+int64(buf.Len()) > this.maxFileSize) {
+		err = this.rotate()
+		if err != nil {
+			return err
+		}
+	}
+	n, err := this.file.Write(buf.Bytes())
+	this.size = this.size + int64(n)
+	if err != nil {
+		return err
+	}
+	return this.file.Sync()
+}
+
+func (this *FileSink) rotate() (err error) {
+	if this.file != nil {
+		err = this.file.Close()
+		if err != nil {
+			return err
+		}
+		this.file = nil
+	}
+	this.sequence++
+	name := fmt.Sprintf("process-history-%v-%04d.ndjson", time.Now().UTC().Format("20060102T150405.000"), this.sequence)
+	if this.gzip {
+		name = name + ".gz"
+	}
+	this.file, err = os.OpenFile(filepath.Join(this.dir, name), os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	this.size = 0
+	log.Println("write archive to", this.file.Name())
+	return nil
+}
+
+func (this *FileSink) Close() error {
+	if this.file == nil {
+		return nil
+	}
+	err := this.file.Close()
+	this.file = nil
+	return err
+}
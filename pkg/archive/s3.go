@@ -0,0 +1,212 @@
+/*
+ * Copyright 2020 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package archive
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/SENERGY-Platform/process-history-cleanup/pkg/camunda"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrUploadVerification = errors.New("unable to verify archive upload")
+
+// s3RequestTimeout limits every upload and verification request, so that a hanging endpoint can not block a run
+const s3RequestTimeout = 5 * time.Minute
+
+type S3Config struct {
+	Endpoint  string //e.g. https://minio:9000; objects are addressed path style as {Endpoint}/{Bucket}/{key}
+	Bucket    string
+	Region    string
+	AccessKey string
+	SecretKey string
+	Prefix    string
+	Gzip      bool
+}
+
+// S3Sink uploads every Write as one object per tenant, process definition key and end date
+// object keys look like {prefix}/tenant={tenant}/process_definition_key={key}/end_date={yyyy-mm-dd}/{batch}.ndjson.gz
+type S3Sink struct {
+	config   S3Config
+	client   *http.Client
+	batch    string
+	sequence int
+}
+
+func NewS3Sink(config S3Config) (*S3Sink, error) {
+	if config.Endpoint == "" || config.Bucket == "" {
+		return nil, errors.New("expect archive_s3_endpoint and archive_s3_bucket for s3 archive")
+	}
+	if config.Region == "" {
+		config.Region = "us-east-1"
+	}
+	config.Endpoint = strings.TrimSuffix(config.Endpoint, "/")
+	config.Prefix = strings.Trim(config.Prefix, "/")
+	return &S3Sink{
+		config: config,
+		client: &http.Client{Timeout: s3RequestTimeout},
+		batch:  time.Now().UTC().Format("20060102T150405.000"),
+	}, nil
+}
+
+func (this *S3Sink) Write(ctx context.Context, records []Record) (err error) {
+	partitions := map[string][]Record{}
+	for _, record := range records {
+		key := this.partition(record)
+		partitions[key] = append(partitions[key], record)
+	}
+	keys := []string{}
+	for key := range partitions {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	this.sequence++
+	for _, partition := range keys {
+		name := fmt.Sprintf("%v-%04d.ndjson", this.batch, this.sequence)
+		if this.config.Gzip {
+			name = name + ".gz"
+		}
+		err = this.upload(ctx, path.Join(partition, name), partitions[partition])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (this *S3Sink) partition(record Record) string {
+	endDate := "unknown"
+	endTime, err := time.Parse(camunda.CamundaTimeFormat, record.Instance.EndTime)
+	if err == nil {
+		endDate = endTime.UTC().Format("2006-01-02")
+	}
+	tenant := record.Instance.TenantId
+	if tenant == "" {
+		tenant = "-"
+	}
+	key := record.Instance.ProcessDefinitionKey
+	if key == "" {
+		key = "-"
+	}
+	return path.Join(this.config.Prefix, "tenant="+tenant, "process_definition_key="+key, "end_date="+endDate)
+}
+
+func (this *S3Sink) upload(ctx context.Context, key string, records []Record) (err error) {
+	buf := &bytes.Buffer{}
+	contentType := "application/x-ndjson"
+	if this.config.Gzip {
+		contentType = "application/gzip"
+		writer := gzip.NewWriter(buf)
+		err = encodeNdjson(writer, records)
+		if err != nil {
+			return err
+		}
+		err = writer.Close()
+		if err != nil {
+			return err
+		}
+	} else {
+		err = encodeNdjson(buf, records)
+		if err != nil {
+			return err
+		}
+	}
+	body := buf.Bytes()
+	checksum := md5.Sum(body)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, this.objectUrl(key), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Content-MD5", base64.StdEncoding.EncodeToString(checksum[:]))
+	signV4(req, body, this.config.Region, this.config.AccessKey, this.config.SecretKey, time.Now())
+	resp, err := this.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("unable to upload archive %v: %v %v", key, resp.Status, string(respBody))
+	}
+	//s3 rejects bodies that do not match Content-MD5; the etag is no md5 for sse-kms, sse-c and multipart uploads
+	err = this.verify(ctx, key, int64(len(body)))
+	if err != nil {
+		return err
+	}
+	log.Printf("uploaded %v archive records to %v", len(records), key)
+	return nil
+}
+
+// verify checks that the object exists with the expected size
+func (this *S3Sink) verify(ctx context.Context, key string, size int64) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, this.objectUrl(key), nil)
+	if err != nil {
+		return err
+	}
+	signV4(req, nil, this.config.Region, this.config.AccessKey, this.config.SecretKey, time.Now())
+	resp, err := this.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%w: head %v: %v", ErrUploadVerification, key, resp.Status)
+	}
+	length, err := strconv.ParseInt(resp.Header.Get("Content-Length"), 10, 64)
+	if err != nil || length != size {
+		return fmt.Errorf("%w: %v has size %v, expected %v", ErrUploadVerification, key, resp.Header.Get("Content-Length"), size)
+	}
+	return nil
+}
+
+func (this *S3Sink) objectUrl(key string) string {
+	return this.config.Endpoint + "/" + uriEncode(this.config.Bucket, false) + "/" + uriEncode(key, false)
+}
+
+func (this *S3Sink) Close() error {
+	return nil
+}
+
+// uriEncode encodes like aws signature version 4 expects it; '/' is kept if encodeSlash is false
+func uriEncode(value string, encodeSlash bool) string {
+	result := strings.Builder{}
+	for _, b := range []byte(value) {
+		switch {
+		case (b >= 'A' && b <= 'Z') || (b >= 'a' && b <= 'z') || (b >= '0' && b <= '9') || b == '-' || b == '_' || b == '.' || b == '~':
+			result.WriteByte(b)
+		case b == '/' && !encodeSlash:
+			result.WriteByte(b)
+		default:
+			result.WriteString(fmt.Sprintf("%%%02X", b))
+		}
+	}
+	return result.String()
+}
@@ -0,0 +1,128 @@
+/*
+ * Copyright 2020 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package camunda
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"github.com/SENERGY-Platform/process-history-cleanup/pkg/configuration"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+	"net/http"
+	"os"
+	"strings"
+)
+
+const (
+	AuthNone   = configuration.AuthNone
+	AuthBasic  = configuration.AuthBasic
+	AuthBearer = configuration.AuthBearer
+	AuthOAuth2 = configuration.AuthOAuth2
+)
+
+// newAuth wraps next with a http.RoundTripper that adds the configured credentials to every request
+// secrets are read from their *_file config fields on use, so that mounted secrets may be rotated without restart
+// tokenTransport is used to request oauth2 tokens
+func newAuth(config configuration.Config, next http.RoundTripper, tokenTransport http.RoundTripper) http.RoundTripper {
+	switch config.EngineAuth {
+	case "", AuthNone:
+		return next
+	case AuthBasic:
+		return &authTransport{next: next, header: func() (string, error) {
+			password, err := readSecret(config.EnginePassword, config.EnginePasswordFile)
+			if err != nil {
+				return "", err
+			}
+			return "Basic " + base64.StdEncoding.EncodeToString([]byte(config.EngineUser+":"+password)), nil
+		}}
+	case AuthBearer:
+		return &authTransport{next: next, header: func() (string, error) {
+			token, err := readSecret(config.EngineToken, config.EngineTokenFile)
+			if err != nil {
+				return "", err
+			}
+			return "Bearer " + token, nil
+		}}
+	case AuthOAuth2:
+		source := oauth2.ReuseTokenSource(nil, &clientCredentialsSource{config: config, client: &http.Client{Transport: tokenTransport}})
+		return &authTransport{next: next, header: func() (string, error) {
+			token, err := source.Token()
+			if err != nil {
+				return "", err
+			}
+			return token.Type() + " " + token.AccessToken, nil
+		}}
+	default:
+		return &authTransport{next: next, header: func() (string, error) {
+			return "", fmt.Errorf("unknown engine_auth %v", config.EngineAuth)
+		}}
+	}
+}
+
+type authTransport struct {
+	next   http.RoundTripper
+	header func() (string, error)
+}
+
+func (this *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	header, err := this.header()
+	if err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, fmt.Errorf("%w: %w", ErrAuth, err)
+	}
+	//a RoundTripper must not modify the original request
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", header)
+	return this.next.RoundTrip(req)
+}
+
+// clientCredentialsSource fetches a new token from EngineTokenUrl on every call
+// it is wrapped by oauth2.ReuseTokenSource, which only calls it if the current token is expired
+type clientCredentialsSource struct {
+	config configuration.Config
+	client *http.Client
+}
+
+func (this *clientCredentialsSource) Token() (*oauth2.Token, error) {
+	secret, err := readSecret(this.config.EngineClientSecret, this.config.EngineClientSecretFile)
+	if err != nil {
+		return nil, err
+	}
+	credentials := clientcredentials.Config{
+		ClientID:     this.config.EngineClientId,
+		ClientSecret: secret,
+		TokenURL:     this.config.EngineTokenUrl,
+		Scopes:       this.config.EngineScopes,
+	}
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, this.client)
+	return credentials.Token(ctx)
+}
+
+// readSecret returns the trimmed content of file if set, otherwise value
+func readSecret(value string, file string) (string, error) {
+	if file == "" {
+		return value, nil
+	}
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return "", fmt.Errorf("unable to read secret file: %w", err)
+	}
+	return strings.TrimSpace(string(content)), nil
+}
@@ -0,0 +1,140 @@
+/*
+ * Copyright 2020 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package camunda
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const batchPollInterval = time.Second
+
+const deleteReason = "process-history-cleanup"
+
+// RemoveProcessInstanceHistoryBatch deletes the given historic process instances with a camunda batch
+// and blocks until the batch is finished
+func (this *Camunda) RemoveProcessInstanceHistoryBatch(ctx context.Context, ids []string) (err error) {
+	if len(ids) == 0 {
+		return nil
+	}
+	batch, err := this.DeleteProcessInstanceHistoryAsync(ctx, ids)
+	if err != nil {
+		return err
+	}
+	log.Printf("started batch %v to delete %v process instance histories", batch.Id, len(ids))
+	return this.WaitForBatch(ctx, batch.Id)
+}
+
+func (this *Camunda) DeleteProcessInstanceHistoryAsync(ctx context.Context, ids []string) (result Batch, err error) {
+	err = this.post(ctx, "/engine-rest/history/process-instance/delete", DeleteHistoricProcessInstancesRequest{
+		HistoricProcessInstanceIds: ids,
+		DeleteReason:               deleteReason,
+		FailIfNotExists:            false,
+	}, &result)
+	return result, err
+}
+
+// WaitForBatch polls the batch statistics until the runtime batch is removed and the historic batch has an end time
+// returns an error wrapping ErrBatchFailed if the only remaining jobs of the batch are failed jobs
+// if ctx is done, the batch keeps running in the engine
+func (this *Camunda) WaitForBatch(ctx context.Context, id string) (err error) {
+	lastCompleted := int64(-1)
+	for {
+		statistics, found, err := this.GetBatchStatistics(ctx, id)
+		if err != nil {
+			return err
+		}
+		if !found {
+			break
+		}
+		if statistics.CompletedJobs != lastCompleted {
+			lastCompleted = statistics.CompletedJobs
+			log.Printf("batch %v: %v/%v jobs completed, %v remaining, %v failed", id, statistics.CompletedJobs, statistics.TotalJobs, statistics.RemainingJobs, statistics.FailedJobs)
+		}
+		if statistics.FailedJobs > 0 && statistics.RemainingJobs <= statistics.FailedJobs {
+			return this.batchFailure(ctx, statistics)
+		}
+		err = sleep(ctx, batchPollInterval)
+		if err != nil {
+			return err
+		}
+	}
+	for {
+		historic, err := this.GetHistoricBatch(ctx, id)
+		if errors.Is(err, ErrNotFound) {
+			//history level may be too low to record batches
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if historic.EndTime != "" {
+			log.Printf("batch %v finished at %v", id, historic.EndTime)
+			return nil
+		}
+		err = sleep(ctx, batchPollInterval)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (this *Camunda) batchFailure(ctx context.Context, statistics BatchStatistics) error {
+	jobs, err := this.ListFailedJobs(ctx, statistics.BatchJobDefinitionId)
+	if err != nil {
+		log.Println("WARNING: unable to list failed jobs of batch", statistics.Id, err)
+	}
+	messages := []string{}
+	for _, job := range jobs {
+		log.Printf("ERROR: batch %v: job %v failed: %v", statistics.Id, job.Id, job.ExceptionMessage)
+		messages = append(messages, job.ExceptionMessage)
+	}
+	return fmt.Errorf("%w: batch %v has %v failed jobs: %v", ErrBatchFailed, statistics.Id, statistics.FailedJobs, strings.Join(messages, "; "))
+}
+
+// GetBatchStatistics returns found=false if the batch is no longer a runtime batch
+func (this *Camunda) GetBatchStatistics(ctx context.Context, id string) (result BatchStatistics, found bool, err error) {
+	list := []BatchStatistics{}
+	err = this.get(ctx, "/engine-rest/batch/statistics?"+url.Values{"batchId": []string{id}}.Encode(), &list)
+	if err != nil {
+		return result, false, err
+	}
+	if len(list) == 0 {
+		return result, false, nil
+	}
+	return list[0], true, nil
+}
+
+func (this *Camunda) GetHistoricBatch(ctx context.Context, id string) (result HistoricBatch, err error) {
+	err = this.get(ctx, "/engine-rest/history/batch/"+url.PathEscape(id), &result)
+	return result, err
+}
+
+func (this *Camunda) ListFailedJobs(ctx context.Context, jobDefinitionId string) (result []Job, err error) {
+	params := url.Values{
+		"jobDefinitionId": []string{jobDefinitionId},
+		"withException":   []string{"true"},
+		"noRetriesLeft":   []string{"true"},
+	}
+	err = this.get(ctx, "/engine-rest/job?"+params.Encode(), &result)
+	return result, err
+}
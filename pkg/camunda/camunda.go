@@ -19,12 +19,21 @@ package camunda
 import (
 	"github.com/SENERGY-Platform/process-history-cleanup/pkg/configuration"
 	"log"
+	"net/http"
 	"time"
 )
 
+// a single request must not block a cleanup run forever; batch deletions are polled and not waited for in one request
+const defaultRequestTimeout = 5 * time.Minute
+
 type Camunda struct {
-	config   configuration.Config
-	location *time.Location
+	config    configuration.Config
+	location  *time.Location
+	client    *http.Client
+	transport http.RoundTripper
+	retry     retryPolicy
+	throttle  *throttle //nil if no rate limit is configured
+	onRequest func()    //nil if no callback is registered
 }
 
 func New(config configuration.Config) *Camunda {
@@ -33,5 +42,60 @@ func New(config configuration.Config) *Camunda {
 		log.Println("unable to load location")
 		location, _ = time.LoadLocation("Europe/Berlin")
 	}
-	return &Camunda{config: config, location: location}
+	//auth -> throttle -> tls; token requests of the auth layer are not rate limited
+	transport := newTransport(config)
+	limited := newThrottle(config, transport)
+	t, _ := limited.(*throttle)
+	return &Camunda{
+		config:    config,
+		location:  location,
+		client:    &http.Client{Transport: newAuth(config, limited, transport), Timeout: requestTimeout(config)},
+		transport: transport,
+		retry:     newRetryPolicy(config),
+		throttle:  t,
+	}
+}
+
+func requestTimeout(config configuration.Config) time.Duration {
+	if config.EngineRequestTimeout != "" {
+		parsed, err := time.ParseDuration(config.EngineRequestTimeout)
+		if err != nil {
+			log.Println("WARNING: invalid engine_request_timeout, use default", err)
+		} else {
+			return parsed
+		}
+	}
+	return defaultRequestTimeout
+}
+
+// MaxRequestInterval returns the longest time a running cleanup may wait between two finished engine requests:
+// one request timeout and one retry delay
+func MaxRequestInterval(config configuration.Config) time.Duration {
+	return requestTimeout(config) + newRetryPolicy(config).maxDelay
+}
+
+// OnRateLimitChange registers a callback for changes of the request rate limit
+// the callback is called once with the current limit; it is never called if no rate limit is configured
+func (this *Camunda) OnRateLimitChange(f func(limit float64)) {
+	if this.throttle == nil {
+		return
+	}
+	this.throttle.mux.Lock()
+	this.throttle.onChange = f
+	this.throttle.mux.Unlock()
+	f(this.throttle.limit())
+}
+
+// OnRequest registers a callback that is called after every finished engine request, successful or not
+// long batch waits poll the engine, so the callback is called regularly while a cleanup run makes progress
+func (this *Camunda) OnRequest(f func()) {
+	this.onRequest = f
+}
+
+// Close releases the idle keep-alive connections to the engine
+// the client stays usable and opens new connections if needed
+func (this *Camunda) Close() {
+	if closer, ok := this.transport.(interface{ CloseIdleConnections() }); ok {
+		closer.CloseIdleConnections()
+	}
 }
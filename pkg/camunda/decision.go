@@ -0,0 +1,68 @@
+/*
+ * Copyright 2020 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package camunda
+
+import (
+	"context"
+	"log"
+	"net/url"
+	"time"
+)
+
+func (this *Camunda) ListHistoricDecisionInstancesEvaluatedBefore(ctx context.Context, limit string, offset string, before time.Time, filter DecisionFilter) (result HistoricDecisionInstances, err error) {
+	params := url.Values{
+		"maxResults":      []string{limit},
+		"firstResult":     []string{offset},
+		"sortBy":          []string{"evaluationTime"},
+		"sortOrder":       []string{"asc"},
+		"evaluatedBefore": []string{before.In(this.location).Format(CamundaTimeFormat)},
+	}
+	filter.apply(params)
+
+	path := "/engine-rest/history/decision-instance?" + params.Encode()
+	err = this.get(ctx, path, &result)
+	if err != nil {
+		return result, err
+	}
+	if this.config.Debug {
+		log.Printf("DEBUG: read %v elements from %v", len(result), path)
+	}
+	return result, err
+}
+
+// RemoveHistoricDecisionInstances deletes the given historic decision instances with a camunda batch
+// and blocks until the batch is finished; the rest api has no endpoint to delete single decision instances
+func (this *Camunda) RemoveHistoricDecisionInstances(ctx context.Context, ids []string) (err error) {
+	if len(ids) == 0 {
+		return nil
+	}
+	batch := Batch{}
+	err = this.post(ctx, "/engine-rest/history/decision-instance/delete", DeleteHistoricDecisionInstancesRequest{
+		HistoricDecisionInstanceIds: ids,
+		DeleteReason:                deleteReason,
+	}, &batch)
+	if err != nil {
+		return err
+	}
+	log.Printf("started batch %v to delete %v decision instance histories", batch.Id, len(ids))
+	return this.WaitForBatch(ctx, batch.Id)
+}
+
+// ListDecisionTenantIds returns the distinct tenant ids of all historic decision instances
+func (this *Camunda) ListDecisionTenantIds(ctx context.Context) (result []string, err error) {
+	return this.distinctTenantIds(ctx, "/engine-rest/history/decision-instance", url.Values{})
+}
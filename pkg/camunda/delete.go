@@ -17,27 +17,17 @@
 package camunda
 
 import (
-	"fmt"
-	"io/ioutil"
-	"net/http"
+	"context"
+	"errors"
 	"net/url"
 )
 
-func (this *Camunda) RemoveProcessInstanceHistory(id string) (err error) {
+// RemoveProcessInstanceHistory treats unknown ids as success because the history is already gone
+func (this *Camunda) RemoveProcessInstanceHistory(ctx context.Context, id string) (err error) {
 	path := "/engine-rest/history/process-instance/" + url.QueryEscape(id)
-	req, err := http.NewRequest("DELETE", this.config.EngineUrl+path, nil)
-	if err != nil {
-		return err
+	err = this.request(ctx, "DELETE", path, nil, nil)
+	if errors.Is(err, ErrNotFound) {
+		return nil
 	}
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-	if resp.StatusCode >= 300 {
-		buf, _ := ioutil.ReadAll(resp.Body)
-		err = fmt.Errorf("%w %v %v", ErrUnexpectedResponse, resp.Status, string(buf))
-		return err
-	}
-	return
+	return err
 }
@@ -0,0 +1,42 @@
+/*
+ * Copyright 2020 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package camunda
+
+import (
+	"context"
+	"net/url"
+)
+
+func (this *Camunda) ListHistoricActivityInstances(ctx context.Context, processInstanceId string) (result []HistoricActivityInstance, err error) {
+	params := url.Values{
+		"processInstanceId": []string{processInstanceId},
+		"sortBy":            []string{"startTime"},
+		"sortOrder":         []string{"asc"},
+	}
+	err = this.get(ctx, "/engine-rest/history/activity-instance?"+params.Encode(), &result)
+	return result, err
+}
+
+// ListHistoricVariableInstances does not deserialize object values; they are returned in their serialized form
+func (this *Camunda) ListHistoricVariableInstances(ctx context.Context, processInstanceId string) (result []HistoricVariableInstance, err error) {
+	params := url.Values{
+		"processInstanceId": []string{processInstanceId},
+		"deserializeValues": []string{"false"},
+	}
+	err = this.get(ctx, "/engine-rest/history/variable-instance?"+params.Encode(), &result)
+	return result, err
+}
@@ -0,0 +1,75 @@
+/*
+ * Copyright 2020 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package camunda
+
+import (
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// HistoryFilter restricts history queries to a subset of process instances
+// the zero value matches every instance
+type HistoryFilter struct {
+	ProcessDefinitionKey      string
+	ProcessDefinitionKeyNotIn []string
+	TenantIdIn                []string
+	WithoutTenantId           bool
+}
+
+func (this HistoryFilter) apply(params url.Values) {
+	if this.ProcessDefinitionKey != "" {
+		params["processDefinitionKey"] = []string{this.ProcessDefinitionKey}
+	}
+	if len(this.ProcessDefinitionKeyNotIn) > 0 {
+		params["processDefinitionKeyNotIn"] = []string{strings.Join(this.ProcessDefinitionKeyNotIn, ",")}
+	}
+	if len(this.TenantIdIn) > 0 {
+		params["tenantIdIn"] = []string{strings.Join(this.TenantIdIn, ",")}
+	}
+	if this.WithoutTenantId {
+		params["withoutTenantId"] = []string{"true"}
+	}
+}
+
+// DecisionFilter restricts history queries to a subset of decision instances
+// the zero value matches every instance
+// camunda has no decisionDefinitionKeyNotIn parameter: list requests of the rest client return the instances of
+// DecisionDefinitionKeyNotIn too, callers have to drop them with Excludes
+type DecisionFilter struct {
+	DecisionDefinitionKeyIn    []string
+	DecisionDefinitionKeyNotIn []string
+	TenantIdIn                 []string
+	WithoutTenantId            bool
+}
+
+// Excludes reports if the instance is excluded by DecisionDefinitionKeyNotIn
+func (this DecisionFilter) Excludes(instance HistoricDecisionInstance) bool {
+	return slices.Contains(this.DecisionDefinitionKeyNotIn, instance.DecisionDefinitionKey)
+}
+
+func (this DecisionFilter) apply(params url.Values) {
+	if len(this.DecisionDefinitionKeyIn) > 0 {
+		params["decisionDefinitionKeyIn"] = []string{strings.Join(this.DecisionDefinitionKeyIn, ",")}
+	}
+	if len(this.TenantIdIn) > 0 {
+		params["tenantIdIn"] = []string{strings.Join(this.TenantIdIn, ",")}
+	}
+	if this.WithoutTenantId {
+		params["withoutTenantId"] = []string{"true"}
+	}
+}
@@ -17,18 +17,14 @@
 package camunda
 
 import (
-	"encoding/json"
-	"fmt"
-	"io"
+	"context"
 	"log"
-	"net/http"
 	"net/url"
-	"runtime/debug"
 	"time"
 	_ "time/tzdata"
 )
 
-func (this *Camunda) ListHistory(limit string, offset string, sortby string, sortdirection string, finished bool) (result HistoricProcessInstances, err error) {
+func (this *Camunda) ListHistory(ctx context.Context, limit string, offset string, sortby string, sortdirection string, finished bool, filter HistoryFilter) (result HistoricProcessInstances, err error) {
 	params := url.Values{
 		"maxResults":  []string{limit},
 		"firstResult": []string{offset},
@@ -40,35 +36,20 @@ func (this *Camunda) ListHistory(limit string, offset string, sortby string, sor
 	} else {
 		params["unfinished"] = []string{"true"}
 	}
+	filter.apply(params)
 
 	path := "/engine-rest/history/process-instance?" + params.Encode()
-	req, err := http.NewRequest("GET", this.config.EngineUrl+path, nil)
+	err = this.get(ctx, path, &result)
 	if err != nil {
 		return result, err
 	}
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		debug.PrintStack()
-		return result, err
-	}
-	defer resp.Body.Close()
-	if resp.StatusCode >= 300 {
-		buf, _ := io.ReadAll(resp.Body)
-		err = fmt.Errorf("%w %v %v", ErrUnexpectedResponse, resp.Status, string(buf))
-		return result, err
-	}
-	err = json.NewDecoder(resp.Body).Decode(&result)
-	if err != nil {
-		err = fmt.Errorf("%w %v", ErrUnexpectedResponse, err.Error())
-		return result, err
-	}
 	if this.config.Debug {
 		log.Printf("DEBUG: read %v elements from %v", len(result), path)
 	}
 	return result, err
 }
 
-func (this *Camunda) ListHistoryFinishedBefore(limit string, offset string, sortby string, sortdirection string, finished bool, before time.Time) (result HistoricProcessInstances, err error) {
+func (this *Camunda) ListHistoryFinishedBefore(ctx context.Context, limit string, offset string, sortby string, sortdirection string, finished bool, before time.Time, filter HistoryFilter) (result HistoricProcessInstances, err error) {
 	params := url.Values{
 		"maxResults":     []string{limit},
 		"firstResult":    []string{offset},
@@ -81,26 +62,11 @@ func (this *Camunda) ListHistoryFinishedBefore(limit string, offset string, sort
 	} else {
 		params["unfinished"] = []string{"true"}
 	}
+	filter.apply(params)
 
 	path := "/engine-rest/history/process-instance?" + params.Encode()
-	req, err := http.NewRequest("GET", this.config.EngineUrl+path, nil)
-	if err != nil {
-		return result, err
-	}
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		debug.PrintStack()
-		return result, err
-	}
-	defer resp.Body.Close()
-	if resp.StatusCode >= 300 {
-		buf, _ := io.ReadAll(resp.Body)
-		err = fmt.Errorf("%w %v %v", ErrUnexpectedResponse, resp.Status, string(buf))
-		return result, err
-	}
-	err = json.NewDecoder(resp.Body).Decode(&result)
+	err = this.get(ctx, path, &result)
 	if err != nil {
-		err = fmt.Errorf("%w %v", ErrUnexpectedResponse, err.Error())
 		return result, err
 	}
 	if this.config.Debug {
@@ -109,7 +75,7 @@ func (this *Camunda) ListHistoryFinishedBefore(limit string, offset string, sort
 	return result, err
 }
 
-func (this *Camunda) ListHistoryCount(finished bool) (result Count, err error) {
+func (this *Camunda) ListHistoryCount(ctx context.Context, finished bool) (result Count, err error) {
 	params := url.Values{}
 	if finished {
 		params["finished"] = []string{"true"}
@@ -118,24 +84,26 @@ func (this *Camunda) ListHistoryCount(finished bool) (result Count, err error) {
 	}
 
 	path := "/engine-rest/history/process-instance/count?" + params.Encode()
-	req, err := http.NewRequest("GET", this.config.EngineUrl+path, nil)
+	err = this.get(ctx, path, &result)
 	if err != nil {
 		return result, err
 	}
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		debug.PrintStack()
-		return result, err
+	if this.config.Debug {
+		log.Printf("DEBUG: read %v from %v", result.Count, path)
 	}
-	defer resp.Body.Close()
-	if resp.StatusCode >= 300 {
-		buf, _ := io.ReadAll(resp.Body)
-		err = fmt.Errorf("%w %v %v", ErrUnexpectedResponse, resp.Status, string(buf))
-		return result, err
+	return result, err
+}
+
+func (this *Camunda) ListHistoryCountFinishedBefore(ctx context.Context, before time.Time, filter HistoryFilter) (result Count, err error) {
+	params := url.Values{
+		"finished":       []string{"true"},
+		"finishedBefore": []string{before.In(this.location).Format(CamundaTimeFormat)},
 	}
-	err = json.NewDecoder(resp.Body).Decode(&result)
+	filter.apply(params)
+
+	path := "/engine-rest/history/process-instance/count?" + params.Encode()
+	err = this.get(ctx, path, &result)
 	if err != nil {
-		err = fmt.Errorf("%w %v", ErrUnexpectedResponse, err.Error())
 		return result, err
 	}
 	if this.config.Debug {
@@ -0,0 +1,88 @@
+/*
+ * Copyright 2020 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package camunda
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// history that grows independently of process instances
+const (
+	HistoryTypeBatch         = "batch"
+	HistoryTypeJobLog        = "job-log"
+	HistoryTypeUserOperation = "user-operation"
+)
+
+// HistoryLogTypes returns the history types that CleanupHistoryLog can handle
+// the rest api can delete historic batches but neither job log nor user operation log entries
+func (this *Camunda) HistoryLogTypes() []string {
+	return []string{HistoryTypeBatch}
+}
+
+// CleanupHistoryLog removes completed historic batches that ended before the given time
+// /history/batch has no end time filter, so the batches are sorted by end time and checked locally
+// the batches are only counted if remove is false
+func (this *Camunda) CleanupHistoryLog(ctx context.Context, historyType string, before time.Time, batchSize int, remove bool) (count int64, err error) {
+	if historyType != HistoryTypeBatch {
+		return 0, fmt.Errorf("%w: %v", ErrUnsupportedHistoryType, historyType)
+	}
+	offset := 0
+	for {
+		params := url.Values{
+			"completed":   []string{"true"},
+			"sortBy":      []string{"endTime"},
+			"sortOrder":   []string{"asc"},
+			"maxResults":  []string{strconv.Itoa(batchSize)},
+			"firstResult": []string{strconv.Itoa(offset)},
+		}
+		batches := []HistoricBatch{}
+		err = this.get(ctx, "/engine-rest/history/batch?"+params.Encode(), &batches)
+		if err != nil {
+			return count, err
+		}
+		for _, batch := range batches {
+			endTime, err := time.Parse(CamundaTimeFormat, batch.EndTime)
+			if err != nil {
+				log.Println("WARNING: unable to parse end time of batch", batch.Id, batch.EndTime, err)
+				offset++
+				continue
+			}
+			if endTime.After(before) {
+				return count, nil
+			}
+			if !remove {
+				count++
+				offset++
+				continue
+			}
+			err = this.request(ctx, "DELETE", "/engine-rest/history/batch/"+url.PathEscape(batch.Id), nil, nil)
+			if err != nil && !errors.Is(err, ErrNotFound) {
+				return count, err
+			}
+			count++
+		}
+		if len(batches) < batchSize {
+			return count, nil
+		}
+	}
+}
@@ -40,10 +40,128 @@ type HistoricProcessInstance struct {
 
 type HistoricProcessInstances = []HistoricProcessInstance
 
+type HistoricActivityInstance struct {
+	Id                       string  `json:"id"`
+	ParentActivityInstanceId string  `json:"parentActivityInstanceId"`
+	ActivityId               string  `json:"activityId"`
+	ActivityName             string  `json:"activityName"`
+	ActivityType             string  `json:"activityType"`
+	ProcessDefinitionKey     string  `json:"processDefinitionKey"`
+	ProcessDefinitionId      string  `json:"processDefinitionId"`
+	ProcessInstanceId        string  `json:"processInstanceId"`
+	ExecutionId              string  `json:"executionId"`
+	TaskId                   string  `json:"taskId"`
+	CalledProcessInstanceId  string  `json:"calledProcessInstanceId"`
+	CalledCaseInstanceId     string  `json:"calledCaseInstanceId"`
+	Assignee                 string  `json:"assignee"`
+	StartTime                string  `json:"startTime"`
+	EndTime                  string  `json:"endTime"`
+	DurationInMillis         float64 `json:"durationInMillis"`
+	Canceled                 bool    `json:"canceled"`
+	CompleteScope            bool    `json:"completeScope"`
+	TenantId                 string  `json:"tenantId"`
+}
+
+type HistoricVariableInstance struct {
+	Id                   string                 `json:"id"`
+	Name                 string                 `json:"name"`
+	Type                 string                 `json:"type"`
+	Value                interface{}            `json:"value"`
+	ValueInfo            map[string]interface{} `json:"valueInfo"`
+	ProcessDefinitionKey string                 `json:"processDefinitionKey"`
+	ProcessDefinitionId  string                 `json:"processDefinitionId"`
+	ProcessInstanceId    string                 `json:"processInstanceId"`
+	ExecutionId          string                 `json:"executionId"`
+	ActivityInstanceId   string                 `json:"activityInstanceId"`
+	TaskId               string                 `json:"taskId"`
+	TenantId             string                 `json:"tenantId"`
+	ErrorMessage         string                 `json:"errorMessage"`
+	State                string                 `json:"state"`
+	CreateTime           string                 `json:"createTime"`
+}
+
+type HistoricDecisionInstance struct {
+	Id                     string `json:"id"`
+	DecisionDefinitionId   string `json:"decisionDefinitionId"`
+	DecisionDefinitionKey  string `json:"decisionDefinitionKey"`
+	DecisionDefinitionName string `json:"decisionDefinitionName"`
+	EvaluationTime         string `json:"evaluationTime"`
+	ProcessDefinitionKey   string `json:"processDefinitionKey"`
+	ProcessInstanceId      string `json:"processInstanceId"`
+	RootProcessInstanceId  string `json:"rootProcessInstanceId"`
+	TenantId               string `json:"tenantId"`
+}
+
+type HistoricDecisionInstances = []HistoricDecisionInstance
+
+type DeleteHistoricDecisionInstancesRequest struct {
+	HistoricDecisionInstanceIds []string `json:"historicDecisionInstanceIds"`
+	DeleteReason                string   `json:"deleteReason,omitempty"`
+}
+
+type Deployment struct {
+	Id             string `json:"id"`
+	Name           string `json:"name"`
+	Source         string `json:"source"`
+	TenantId       string `json:"tenantId"`
+	DeploymentTime string `json:"deploymentTime"`
+}
+
 var ErrUnexpectedResponse = errors.New("unexpected camunda response")
+var ErrNotFound = errors.New("camunda resource not found")
+var ErrBatchFailed = errors.New("camunda batch has failed jobs")
+var ErrRetriesExhausted = errors.New("camunda request retries exhausted")
+var ErrAuth = errors.New("unable to authenticate camunda request")
 
 type Count struct {
 	Count int64 `json:"count"`
 }
 
 var CamundaTimeFormat = "2006-01-02T15:04:05.000Z0700"
+
+type HistoricProcessInstanceQuery struct {
+	ProcessInstanceIds []string `json:"processInstanceIds,omitempty"`
+}
+
+type DeleteHistoricProcessInstancesRequest struct {
+	HistoricProcessInstanceIds []string `json:"historicProcessInstanceIds"`
+	DeleteReason               string   `json:"deleteReason,omitempty"`
+	FailIfNotExists            bool     `json:"failIfNotExists"`
+}
+
+type Batch struct {
+	Id                     string `json:"id"`
+	Type                   string `json:"type"`
+	TotalJobs              int64  `json:"totalJobs"`
+	BatchJobsPerSeed       int64  `json:"batchJobsPerSeed"`
+	InvocationsPerBatchJob int64  `json:"invocationsPerBatchJob"`
+	SeedJobDefinitionId    string `json:"seedJobDefinitionId"`
+	MonitorJobDefinitionId string `json:"monitorJobDefinitionId"`
+	BatchJobDefinitionId   string `json:"batchJobDefinitionId"`
+	Suspended              bool   `json:"suspended"`
+	TenantId               string `json:"tenantId"`
+	CreateUserId           string `json:"createUserId"`
+}
+
+type BatchStatistics struct {
+	Batch
+	RemainingJobs int64 `json:"remainingJobs"`
+	CompletedJobs int64 `json:"completedJobs"`
+	FailedJobs    int64 `json:"failedJobs"`
+}
+
+type HistoricBatch struct {
+	Batch
+	StartTime string `json:"startTime"`
+	EndTime   string `json:"endTime"`
+}
+
+type Job struct {
+	Id                string `json:"id"`
+	JobDefinitionId   string `json:"jobDefinitionId"`
+	Retries           int64  `json:"retries"`
+	ExceptionMessage  string `json:"exceptionMessage"`
+	FailedActivityId  string `json:"failedActivityId"`
+	ProcessInstanceId string `json:"processInstanceId"`
+	TenantId          string `json:"tenantId"`
+}
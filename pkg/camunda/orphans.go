@@ -0,0 +1,118 @@
+/*
+ * Copyright 2020 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package camunda
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+)
+
+// history types that may contain rows of process instances that no longer exist
+const (
+	HistoryTypeActivityInstance = "activity-instance"
+	HistoryTypeVariableInstance = "variable-instance"
+	HistoryTypeDetail           = "detail"
+	HistoryTypeTaskInstance     = "task-instance"
+	HistoryTypeIncident         = "incident"
+)
+
+var ErrUnsupportedHistoryType = errors.New("history type is not supported by this backend")
+
+// OrphanHistoryTypes returns the history types that CleanupOrphans can handle
+// the rest api can only delete historic variable instances by id
+func (this *Camunda) OrphanHistoryTypes() []string {
+	return []string{HistoryTypeVariableInstance}
+}
+
+// CleanupOrphans finds historic variable instances whose historic process instance does not exist
+// the orphans are only counted if remove is false
+func (this *Camunda) CleanupOrphans(ctx context.Context, historyType string, batchSize int, remove bool) (count int64, err error) {
+	if historyType != HistoryTypeVariableInstance {
+		return 0, fmt.Errorf("%w: %v", ErrUnsupportedHistoryType, historyType)
+	}
+	offset := 0
+	for {
+		params := url.Values{
+			"maxResults":        []string{strconv.Itoa(batchSize)},
+			"firstResult":       []string{strconv.Itoa(offset)},
+			"sortBy":            []string{"instanceId"},
+			"sortOrder":         []string{"asc"},
+			"deserializeValues": []string{"false"},
+		}
+		variables := []HistoricVariableInstance{}
+		err = this.get(ctx, "/engine-rest/history/variable-instance?"+params.Encode(), &variables)
+		if err != nil {
+			return count, err
+		}
+		processInstanceIds := []string{}
+		for _, variable := range variables {
+			if variable.ProcessInstanceId != "" {
+				processInstanceIds = append(processInstanceIds, variable.ProcessInstanceId)
+			}
+		}
+		existing, err := this.existingProcessInstances(ctx, processInstanceIds)
+		if err != nil {
+			return count, err
+		}
+		//removed variables disappear from the following list requests, kept variables have to be paged over
+		kept := 0
+		for _, variable := range variables {
+			//variables without process instance belong to standalone tasks or cases
+			if variable.ProcessInstanceId == "" || existing[variable.ProcessInstanceId] {
+				kept++
+				continue
+			}
+			if !remove {
+				count++
+				kept++
+				continue
+			}
+			err = this.request(ctx, "DELETE", "/engine-rest/history/variable-instance/"+url.PathEscape(variable.Id), nil, nil)
+			if err != nil && !errors.Is(err, ErrNotFound) {
+				return count, err
+			}
+			count++
+		}
+		if len(variables) < batchSize {
+			return count, nil
+		}
+		offset = offset + kept
+	}
+}
+
+// existingProcessInstances sends the ids in the body of a query, because a batch of ids may exceed url length limits
+func (this *Camunda) existingProcessInstances(ctx context.Context, ids []string) (result map[string]bool, err error) {
+	result = map[string]bool{}
+	if len(ids) == 0 {
+		return result, nil
+	}
+	params := url.Values{
+		"maxResults": []string{strconv.Itoa(len(ids))},
+	}
+	instances := HistoricProcessInstances{}
+	err = this.query(ctx, "/engine-rest/history/process-instance?"+params.Encode(), HistoricProcessInstanceQuery{ProcessInstanceIds: ids}, &instances)
+	if err != nil {
+		return result, err
+	}
+	for _, instance := range instances {
+		result[instance.Id] = true
+	}
+	return result, nil
+}
@@ -0,0 +1,139 @@
+/*
+ * Copyright 2020 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package camunda
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+)
+
+// get requests this.config.EngineUrl+path and decodes the json response into result
+func (this *Camunda) get(ctx context.Context, path string, result interface{}) (err error) {
+	return this.request(ctx, "GET", path, nil, result)
+}
+
+// post sends body as json to this.config.EngineUrl+path and decodes the json response into result
+func (this *Camunda) post(ctx context.Context, path string, body interface{}, result interface{}) (err error) {
+	return this.request(ctx, "POST", path, body, result)
+}
+
+// query sends a read-only query with a json body, which may be retried like a GET request
+// queries use a body instead of url parameters if the parameters may exceed url length limits
+func (this *Camunda) query(ctx context.Context, path string, body interface{}, result interface{}) (err error) {
+	return this.send(ctx, "POST", path, body, result, true)
+}
+
+// request ignores the response body if result is nil
+// a 404 response results in an error wrapping ErrNotFound
+// transient errors are retried with jittered exponential back-off; if all retries fail, the error wraps ErrRetriesExhausted
+// POST requests may already have been processed by the engine (e.g. creating a batch) and are not repeated
+func (this *Camunda) request(ctx context.Context, method string, path string, body interface{}, result interface{}) (err error) {
+	return this.send(ctx, method, path, body, result, method != http.MethodPost)
+}
+
+// send is request with an explicit retry classification
+// requests that are not idempotent are only retried for optimistic locking conflicts
+func (this *Camunda) send(ctx context.Context, method string, path string, body interface{}, result interface{}, idempotent bool) (err error) {
+	var reqBody []byte
+	if body != nil {
+		buf := &bytes.Buffer{}
+		err = json.NewEncoder(buf).Encode(body)
+		if err != nil {
+			return err
+		}
+		reqBody = buf.Bytes()
+	}
+	for retry := 0; ; retry++ {
+		var retryable bool
+		retryable, err = this.requestOnce(ctx, method, path, reqBody, result, idempotent)
+		if err == nil || !retryable || ctx.Err() != nil {
+			return err
+		}
+		if retry >= this.retry.maxRetries {
+			if this.retry.maxRetries == 0 {
+				return err
+			}
+			return fmt.Errorf("%w: %v %v failed %v times, last error: %v", ErrRetriesExhausted, method, path, retry+1, err.Error())
+		}
+		delay := this.retry.delay(retry)
+		log.Println("WARNING: retry", method, path, "in", delay.Round(time.Millisecond), "after error:", err)
+		err = sleep(ctx, delay)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// requestOnce sends a single request; retryable reports if a failure is transient
+func (this *Camunda) requestOnce(ctx context.Context, method string, path string, body []byte, result interface{}, idempotent bool) (retryable bool, err error) {
+	var reqBody io.Reader
+	if body != nil {
+		reqBody = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, this.config.EngineUrl+path, reqBody)
+	if err != nil {
+		return false, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := this.client.Do(req)
+	if this.onRequest != nil {
+		this.onRequest()
+	}
+	if err != nil {
+		return idempotent && retryableError(err), err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		buf, _ := io.ReadAll(resp.Body)
+		err = fmt.Errorf("%w %w %v %v", ErrUnexpectedResponse, ErrNotFound, resp.Status, string(buf))
+		return false, err
+	}
+	if resp.StatusCode >= 300 {
+		buf, _ := io.ReadAll(resp.Body)
+		err = fmt.Errorf("%w %v %v", ErrUnexpectedResponse, resp.Status, string(buf))
+		return retryableResponse(idempotent, resp.StatusCode, string(buf)), err
+	}
+	if result == nil {
+		return false, nil
+	}
+	err = json.NewDecoder(resp.Body).Decode(result)
+	if err != nil {
+		err = fmt.Errorf("%w %v", ErrUnexpectedResponse, err.Error())
+		return false, err
+	}
+	return false, nil
+}
+
+// sleep waits for the given duration and returns ctx.Err() if the context is done earlier
+func sleep(ctx context.Context, duration time.Duration) error {
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
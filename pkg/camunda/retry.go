@@ -0,0 +1,123 @@
+/*
+ * Copyright 2020 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package camunda
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"github.com/SENERGY-Platform/process-history-cleanup/pkg/configuration"
+	"io"
+	"log"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	defaultRetryBaseDelay = 500 * time.Millisecond
+	defaultRetryMaxDelay  = 30 * time.Second
+)
+
+type retryPolicy struct {
+	maxRetries int
+	baseDelay  time.Duration
+	maxDelay   time.Duration
+}
+
+func newRetryPolicy(config configuration.Config) retryPolicy {
+	result := retryPolicy{
+		maxRetries: max(config.EngineMaxRetries, 0),
+		baseDelay:  defaultRetryBaseDelay,
+		maxDelay:   defaultRetryMaxDelay,
+	}
+	if config.EngineRetryBaseDelay != "" {
+		parsed, err := time.ParseDuration(config.EngineRetryBaseDelay)
+		if err != nil {
+			log.Println("WARNING: invalid engine_retry_base_delay, use default", err)
+		} else {
+			result.baseDelay = parsed
+		}
+	}
+	if config.EngineRetryMaxDelay != "" {
+		parsed, err := time.ParseDuration(config.EngineRetryMaxDelay)
+		if err != nil {
+			log.Println("WARNING: invalid engine_retry_max_delay, use default", err)
+		} else {
+			result.maxDelay = parsed
+		}
+	}
+	return result
+}
+
+// delay returns a random duration between 0 and the exponential back-off of the given retry (full jitter)
+func (this retryPolicy) delay(retry int) time.Duration {
+	backoff := this.maxDelay
+	if retry < 32 {
+		backoff = min(this.maxDelay, this.baseDelay<<retry)
+	}
+	if backoff <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(backoff) + 1))
+}
+
+// retryableError reports if an idempotent request that failed before receiving a response may be repeated
+// only network errors are transient; canceled contexts, tls/certificate problems and auth errors are not
+func retryableError(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, ErrAuth) {
+		return false
+	}
+	if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) {
+		return true
+	}
+	//*url.Error implements net.Error itself, only the wrapped error tells if the network failed
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		err = urlErr.Err
+	}
+	var certErr *tls.CertificateVerificationError
+	var recordErr tls.RecordHeaderError
+	var authorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidErr x509.CertificateInvalidError
+	if errors.As(err, &certErr) || errors.As(err, &recordErr) || errors.As(err, &authorityErr) || errors.As(err, &hostnameErr) || errors.As(err, &invalidErr) {
+		return false
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// retryableResponse reports if a response indicates a transient engine problem
+// 502, 503 and 504 are returned by proxies or overloaded engines; 500 is only retried for optimistic locking conflicts
+// 4xx responses are never retried and requests that are not idempotent are only retried for optimistic locking conflicts,
+// because a proxy may report a timeout for a request that the engine has processed
+func retryableResponse(idempotent bool, status int, body string) bool {
+	switch status {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return idempotent
+	case http.StatusInternalServerError:
+		return strings.Contains(body, "OptimisticLockingException")
+	default:
+		return false
+	}
+}
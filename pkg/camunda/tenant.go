@@ -0,0 +1,79 @@
+/*
+ * Copyright 2020 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package camunda
+
+import (
+	"context"
+	"log"
+	"net/url"
+	"strconv"
+)
+
+// ListTenantIds returns the distinct tenant ids of all finished historic process instances
+// tenants are found by their history, so tenants whose deployments were removed are found too
+func (this *Camunda) ListTenantIds(ctx context.Context) (result []string, err error) {
+	return this.distinctTenantIds(ctx, "/engine-rest/history/process-instance", url.Values{"finished": []string{"true"}})
+}
+
+// distinctTenantIds walks the history at path sorted by tenant id
+// the rest api has no distinct query, so every found tenant is skipped with a count request;
+// this needs two requests per tenant instead of reading the whole history
+// instances that are added or removed while walking may hide a tenant until the next call
+func (this *Camunda) distinctTenantIds(ctx context.Context, path string, filter url.Values) (result []string, err error) {
+	for offset := int64(0); ; {
+		params := url.Values{
+			"sortBy":      []string{"tenantId"},
+			"sortOrder":   []string{"asc"},
+			"firstResult": []string{strconv.FormatInt(offset, 10)},
+			"maxResults":  []string{"1"},
+		}
+		for key, values := range filter {
+			params[key] = values
+		}
+		instances := []struct {
+			TenantId string `json:"tenantId"`
+		}{}
+		err = this.get(ctx, path+"?"+params.Encode(), &instances)
+		if err != nil {
+			return result, err
+		}
+		if len(instances) == 0 {
+			break
+		}
+		tenant := instances[0].TenantId
+		params = url.Values{}
+		for key, values := range filter {
+			params[key] = values
+		}
+		if tenant == "" {
+			params["withoutTenantId"] = []string{"true"}
+		} else {
+			params["tenantIdIn"] = []string{tenant}
+			result = append(result, tenant)
+		}
+		count := Count{}
+		err = this.get(ctx, path+"/count?"+params.Encode(), &count)
+		if err != nil {
+			return result, err
+		}
+		offset = offset + max(count.Count, 1)
+	}
+	if this.config.Debug {
+		log.Printf("DEBUG: found %v tenants in %v", len(result), path)
+	}
+	return result, nil
+}
@@ -0,0 +1,115 @@
+/*
+ * Copyright 2020 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package camunda
+
+import (
+	"github.com/SENERGY-Platform/process-history-cleanup/pkg/configuration"
+	"golang.org/x/time/rate"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const defaultLatencyThreshold = time.Second
+
+// throttle is a http.RoundTripper that limits the request rate to the engine
+// with adaptive throttling, the rate is halved on 5xx responses, network errors or slow responses
+// and increased in small steps up to the configured maximum while the engine responds fast
+type throttle struct {
+	next             http.RoundTripper
+	limiter          *rate.Limiter
+	adaptive         bool
+	max              float64
+	min              float64
+	latencyThreshold time.Duration
+	debug            bool
+	mux              sync.Mutex
+	onChange         func(limit float64)
+}
+
+// newThrottle returns next if no rate limit is configured
+func newThrottle(config configuration.Config, next http.RoundTripper) http.RoundTripper {
+	if config.EngineRateLimit <= 0 {
+		if config.EngineAdaptiveThrottling {
+			log.Println("WARNING: engine_adaptive_throttling needs engine_rate_limit > 0")
+		}
+		return next
+	}
+	latencyThreshold := defaultLatencyThreshold
+	if config.EngineLatencyThreshold != "" {
+		parsed, err := time.ParseDuration(config.EngineLatencyThreshold)
+		if err != nil {
+			log.Println("WARNING: invalid engine_latency_threshold, use default", err)
+		} else {
+			latencyThreshold = parsed
+		}
+	}
+	minLimit := config.EngineMinRateLimit
+	if minLimit <= 0 || minLimit > config.EngineRateLimit {
+		minLimit = min(1, config.EngineRateLimit)
+	}
+	return &throttle{
+		next:             next,
+		limiter:          rate.NewLimiter(rate.Limit(config.EngineRateLimit), 1),
+		adaptive:         config.EngineAdaptiveThrottling,
+		max:              config.EngineRateLimit,
+		min:              minLimit,
+		latencyThreshold: latencyThreshold,
+		debug:            config.Debug,
+	}
+}
+
+func (this *throttle) RoundTrip(req *http.Request) (*http.Response, error) {
+	err := this.limiter.Wait(req.Context())
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	resp, err := this.next.RoundTrip(req)
+	if this.adaptive {
+		this.observe(time.Since(start), resp, err)
+	}
+	return resp, err
+}
+
+func (this *throttle) observe(latency time.Duration, resp *http.Response, err error) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	current := float64(this.limiter.Limit())
+	next := current
+	overloaded := err != nil || (resp != nil && resp.StatusCode >= 500) || latency > this.latencyThreshold
+	if overloaded {
+		next = max(this.min, current/2)
+	} else if latency < this.latencyThreshold/2 {
+		next = min(this.max, current+this.max/20)
+	}
+	if next == current {
+		return
+	}
+	this.limiter.SetLimit(rate.Limit(next))
+	if next < current || next == this.max || this.debug {
+		log.Printf("engine rate limit changed from %.2f to %.2f requests per second (latency %v)", current, next, latency.Round(time.Millisecond))
+	}
+	if this.onChange != nil {
+		this.onChange(next)
+	}
+}
+
+func (this *throttle) limit() float64 {
+	return float64(this.limiter.Limit())
+}
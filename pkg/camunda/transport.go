@@ -0,0 +1,92 @@
+/*
+ * Copyright 2020 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package camunda
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"github.com/SENERGY-Platform/process-history-cleanup/pkg/configuration"
+	"log"
+	"net/http"
+	"os"
+)
+
+// newTransport returns the http.RoundTripper used for all engine and token requests
+// if the tls configuration can not be loaded, every request fails with the load error instead of falling back to an insecure default
+func newTransport(config configuration.Config) http.RoundTripper {
+	tlsConfig, err := NewTlsConfig(config)
+	if err != nil {
+		log.Println("ERROR: invalid engine tls config", err)
+		return errorTransport{err: err}
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	return transport
+}
+
+// NewTlsConfig creates the tls config for engine connections
+// EngineCaFile is added to the system cert pool; EngineClientCertFile and EngineClientKeyFile enable mTLS
+func NewTlsConfig(config configuration.Config) (result *tls.Config, err error) {
+	result = &tls.Config{}
+	if config.EngineTlsMinVersion != "" {
+		version, ok := configuration.TlsVersions[config.EngineTlsMinVersion]
+		if !ok {
+			return nil, fmt.Errorf("unknown engine_tls_min_version %v, expect one of 1.0, 1.1, 1.2, 1.3", config.EngineTlsMinVersion)
+		}
+		result.MinVersion = version
+	}
+	if config.EngineCaFile != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			log.Println("WARNING: unable to load system cert pool, use only engine_ca_file", err)
+			pool = x509.NewCertPool()
+		}
+		pem, err := os.ReadFile(config.EngineCaFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read engine_ca_file: %w", err)
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New("engine_ca_file contains no pem encoded certificate")
+		}
+		result.RootCAs = pool
+	}
+	if config.EngineClientCertFile != "" || config.EngineClientKeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(config.EngineClientCertFile, config.EngineClientKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load engine client certificate: %w", err)
+		}
+		result.Certificates = []tls.Certificate{cert}
+	}
+	if config.EngineTlsInsecureSkipVerify {
+		log.Println("WARNING: engine_tls_insecure_skip_verify is set, engine certificates are not verified")
+		result.InsecureSkipVerify = true
+	}
+	return result, nil
+}
+
+type errorTransport struct {
+	err error
+}
+
+func (this errorTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+	return nil, this.err
+}
@@ -17,88 +17,305 @@
 package pkg
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"github.com/SENERGY-Platform/process-history-cleanup/pkg/archive"
 	"github.com/SENERGY-Platform/process-history-cleanup/pkg/camunda"
 	"github.com/SENERGY-Platform/process-history-cleanup/pkg/configuration"
+	"github.com/SENERGY-Platform/process-history-cleanup/pkg/metrics"
+	"github.com/SENERGY-Platform/process-history-cleanup/pkg/scheduler"
 	"log"
 	"strconv"
+	"sync/atomic"
 	"time"
 )
 
-func RunCleanup(config configuration.Config) (err error) {
+const (
+	DeleteStrategySingle = configuration.DeleteStrategySingle
+	DeleteStrategyBatch  = configuration.DeleteStrategyBatch
+)
+
+type cleaner struct {
+	engine           Camunda
+	batchSize        int
+	filterLocally    bool
+	batchDeleter     BatchDeleter  //nil if every instance is deleted with its own request
+	report           *DryRunReport //nil if not in dry-run mode
+	metrics          *metrics.Metrics
+	windows          *scheduler.Windows
+	location         *time.Location //calendar units of max ages are evaluated in this location
+	archive          archive.Sink   //nil if instances are not archived
+	concurrency      int
+	failures         *deleteFailures
+	deleted          atomic.Int64
+	deletedDecisions atomic.Int64
+}
+
+// ErrOutsideMaintenanceWindow is returned if a cleanup run stops because no maintenance window is open
+// the run may be continued when the next window opens
+var ErrOutsideMaintenanceWindow = errors.New("outside of maintenance window")
+
+// RunCleanup removes all historic process instances older than their configured max age
+// the engine is created for this run only; use RunCleanupWithEngine for repeated runs
+// if ctx is done, the run stops after the in-flight deletions and returns an error wrapping ctx.Err()
+// metrics may be nil
+func RunCleanup(ctx context.Context, config configuration.Config, metrics *metrics.Metrics) (err error) {
+	engine, closeEngine, err := NewEngine(config, metrics)
+	if err != nil {
+		return err
+	}
+	defer closeEngine()
+	return RunCleanupWithEngine(ctx, config, engine, metrics)
+}
+
+// RunCleanupWithEngine is RunCleanup with an engine that was created by NewEngine for the same config
+func RunCleanupWithEngine(ctx context.Context, config configuration.Config, engine Camunda, metrics *metrics.Metrics) (err error) {
 	log.Println("RunCleanup")
-	maxAge, err := time.ParseDuration(config.MaxAge)
+	start := time.Now()
+	metrics.RunStarted()
+	defer func() {
+		metrics.RunFinished(time.Since(start), err)
+	}()
+	if config.BatchSize <= 0 {
+		return errors.New("expect batch size > 0")
+	}
+	location, err := time.LoadLocation(config.Location)
+	if err != nil {
+		return err
+	}
+	windows, err := scheduler.WindowsFromConfig(config)
+	if err != nil {
+		return err
+	}
+	if !windows.Open(time.Now()) {
+		return ErrOutsideMaintenanceWindow
+	}
+	targets, err := getRetentionTargets(ctx, config, engine)
+	if err != nil {
+		return err
+	}
+	decisionTargets, err := getDecisionRetentionTargets(ctx, config, engine)
+	if err != nil {
+		return err
+	}
+	logTargets, err := getHistoryLogTargets(config)
 	if err != nil {
 		return err
 	}
-	if config.BatchSize > 0 {
-		return runCleanup(camunda.New(config), maxAge, config.BatchSize, config.FilterLocally)
+	c := &cleaner{
+		engine:        engine,
+		batchSize:     config.BatchSize,
+		filterLocally: config.FilterLocally,
+		metrics:       metrics,
+		windows:       windows,
+		location:      location,
+		concurrency:   config.DeleteConcurrency,
+		failures:      &deleteFailures{},
+	}
+	defer func() {
+		c.logSummary(time.Since(start), err)
+	}()
+	switch config.DeleteStrategy {
+	case "", DeleteStrategySingle:
+	case DeleteStrategyBatch:
+		batchDeleter, ok := engine.(BatchDeleter)
+		if !ok {
+			return fmt.Errorf("backend %v does not support delete_strategy %v", backendName(config), config.DeleteStrategy)
+		}
+		c.batchDeleter = batchDeleter
+	default:
+		return fmt.Errorf("unknown delete_strategy %v", config.DeleteStrategy)
+	}
+	if !config.DryRun {
+		c.archive, err = archive.New(config)
+		if err != nil {
+			return err
+		}
+		if c.archive != nil {
+			defer func() {
+				closeErr := c.archive.Close()
+				if closeErr != nil {
+					log.Println("ERROR: unable to close archive", closeErr)
+					if err == nil {
+						err = closeErr
+					}
+				}
+			}()
+		}
+	}
+	if config.DryRun {
+		log.Println("DRY-RUN: no process instance history will be removed")
+		c.report = NewDryRunReport(config.DryRunListIds)
+		defer c.report.Log()
 	} else {
-		return errors.New("expect batch size > 0")
+		defer c.updateBacklog(ctx, targets)
+	}
+	for _, target := range targets {
+		log.Println("cleanup", target.description, "with max age", target.maxAge.String())
+		err = c.run(ctx, target.filter, target.maxAge)
+		if err != nil {
+			return c.failures.withFailures(err)
+		}
+	}
+	for _, target := range decisionTargets {
+		log.Println("cleanup", target.description, "with max age", target.maxAge.String())
+		err = c.runDecisions(ctx, target)
+		if err != nil {
+			return c.failures.withFailures(err)
+		}
 	}
+	_, err = c.cleanupHistoryLogs(ctx, config, logTargets)
+	if err != nil {
+		return c.failures.withFailures(err)
+	}
+	if config.OrphanCleanup {
+		_, err = c.cleanupOrphans(ctx, config)
+		if err != nil {
+			return c.failures.withFailures(err)
+		}
+	}
+	return c.failures.err()
 }
 
-func runCleanup(camunda Camunda, maxAge time.Duration, batchSize int, filterLocally bool) (err error) {
+// run removes all matching instances older than maxAge
+// the cutoff time is recomputed for every batch
+// in dry-run mode the instances are only added to the report
+func (this *cleaner) run(ctx context.Context, filter camunda.HistoryFilter, maxAge configuration.Duration) (err error) {
 	finished := false
+	offset := 0
+	skipped := 0
+	kept := 0
+	var candidates camunda.HistoricProcessInstances
 	for !finished {
-		if filterLocally {
-			finished, err = runCleanupBatch(camunda, maxAge, batchSize)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if !this.windows.Open(time.Now()) {
+			log.Println("maintenance window closed, stop cleanup")
+			return ErrOutsideMaintenanceWindow
+		}
+		if this.filterLocally {
+			candidates, skipped, finished, err = this.listBatch(ctx, filter, maxAge.Before(time.Now(), this.location), offset)
 		} else {
-			finished, err = runCleanupBatchV2(camunda, maxAge, batchSize)
+			candidates, finished, err = this.listBatchV2(ctx, filter, maxAge.Before(time.Now(), this.location), offset)
+		}
+		if err != nil {
+			return err
 		}
+		kept, err = this.remove(ctx, candidates)
 		if err != nil {
 			return err
 		}
+		//removed instances disappear from the following list requests, kept and skipped instances have to be paged over
+		offset = offset + kept + skipped
 	}
 	return nil
 }
 
-func runCleanupBatchV2(camundaEngine Camunda, maxAge time.Duration, batchSize int) (finished bool, err error) {
-	//we sort so that the old process instances will be processed first
-	//if this instance is younger than the maxAge than all following instances are younger too
-	//all entries will be deleted until we find one that is younger than the max age
-	//this means the offset may be 0 in each batch
-	historyInstances, err := camundaEngine.ListHistoryFinishedBefore(strconv.Itoa(batchSize), "0", "endTime", "asc", true, time.Now().Add(-maxAge))
-	if err != nil {
-		return true, err
+func (this *cleaner) remove(ctx context.Context, instances camunda.HistoricProcessInstances) (kept int, err error) {
+	if this.report != nil {
+		for _, instance := range instances {
+			this.report.Add(instance)
+		}
+		return len(instances), nil
+	}
+	if this.archive != nil {
+		err = this.archiveInstances(ctx, instances)
+		if err != nil {
+			log.Println("ERROR: unable to archive process instances, skip deletion", err)
+			return 0, err
+		}
+	}
+	if this.batchDeleter != nil {
+		ids := []string{}
+		for _, instance := range instances {
+			ids = append(ids, instance.Id)
+		}
+		err = this.batchDeleter.RemoveProcessInstanceHistoryBatch(ctx, ids)
+		if ctx.Err() != nil {
+			//the batch may still be running in the engine, its result is unknown
+			return 0, ctx.Err()
+		}
+		if err == nil {
+			this.deleted.Add(int64(len(ids)))
+		}
+		for _, instance := range instances {
+			if err != nil {
+				this.metrics.DeleteFailed(instance.ProcessDefinitionKey, instance.TenantId)
+			} else {
+				this.metrics.Deleted(instance.ProcessDefinitionKey, instance.TenantId)
+			}
+		}
+		return 0, err
 	}
+	return this.removeEach(ctx, instances)
+}
 
-	for _, instance := range historyInstances {
-		log.Println("delete " + instance.Id)
-		err = camundaEngine.RemoveProcessInstanceHistory(instance.Id)
+// logSummary logs the result of a complete or interrupted run
+func (this *cleaner) logSummary(duration time.Duration, err error) {
+	state := "finished"
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		state = "interrupted"
+	} else if err != nil {
+		state = "failed"
+	}
+	log.Printf("cleanup %v after %v: %v process instance histories removed, %v deletions failed, %v decision instance histories removed", state, duration.Round(time.Second), this.deleted.Load(), this.failures.total(), this.deletedDecisions.Load())
+}
+
+// updateBacklog counts the instances that are still older than their max age
+func (this *cleaner) updateBacklog(ctx context.Context, targets []retentionTarget) {
+	if this.metrics == nil || ctx.Err() != nil {
+		return
+	}
+	backlog := int64(0)
+	for _, target := range targets {
+		count, err := this.engine.ListHistoryCountFinishedBefore(ctx, target.maxAge.Before(time.Now(), this.location), target.filter)
 		if err != nil {
-			return true, err
+			log.Println("WARNING: unable to count backlog", err)
+			return
 		}
+		backlog = backlog + count.Count
+	}
+	this.metrics.SetBacklog(backlog)
+}
+
+func (this *cleaner) listBatchV2(ctx context.Context, filter camunda.HistoryFilter, before time.Time, offset int) (candidates camunda.HistoricProcessInstances, finished bool, err error) {
+	//we sort so that the old process instances will be processed first
+	//if this instance is younger than the maxAge than all following instances are younger too
+	//all entries will be deleted until we find one that is younger than the max age
+	//this means the offset may be 0 in each batch as long as the candidates are removed
+	this.metrics.BatchListed()
+	candidates, err = this.engine.ListHistoryFinishedBefore(ctx, strconv.Itoa(this.batchSize), strconv.Itoa(offset), "endTime", "asc", true, before, filter)
+	if err != nil {
+		return candidates, true, err
 	}
-	return len(historyInstances) != batchSize, nil
+	return candidates, len(candidates) != this.batchSize, nil
 }
 
-func runCleanupBatch(camundaEngine Camunda, maxAge time.Duration, batchSize int) (finished bool, err error) {
+func (this *cleaner) listBatch(ctx context.Context, filter camunda.HistoryFilter, before time.Time, offset int) (candidates camunda.HistoricProcessInstances, skipped int, finished bool, err error) {
 	//we sort so that the old process instances will be processed first
 	//if this instance is younger than the maxAge than all following instances are younger too
 	//all entries will be deleted until we find one that is younger than the max age
-	//this means the offset may be 0 in each batch
-	historyInstances, err := camundaEngine.ListHistory(strconv.Itoa(batchSize), "0", "endTime", "asc", true)
+	//this means the offset may be 0 in each batch as long as the candidates are removed
+	this.metrics.BatchListed()
+	historyInstances, err := this.engine.ListHistory(ctx, strconv.Itoa(this.batchSize), strconv.Itoa(offset), "endTime", "asc", true, filter)
 	if err != nil {
-		return true, err
+		return candidates, skipped, true, err
 	}
 
 	for _, instance := range historyInstances {
 		endTime, err := time.Parse(camunda.CamundaTimeFormat, instance.EndTime)
 		if err != nil {
 			log.Println("WARNING: unable to parse end time", instance.EndTime, err)
-			err = nil
+			skipped++
 			continue
 		}
-		if time.Since(endTime) > maxAge {
-			log.Println("delete " + instance.Id)
-			err = camundaEngine.RemoveProcessInstanceHistory(instance.Id)
-			if err != nil {
-				return true, err
-			}
+		if endTime.Before(before) {
+			candidates = append(candidates, instance)
 		} else {
-			return true, nil
+			return candidates, skipped, true, nil
 		}
 	}
-	return len(historyInstances) != batchSize, nil
+	return candidates, skipped, len(historyInstances) != this.batchSize, nil
 }
@@ -17,7 +17,9 @@
 package configuration
 
 import (
+	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"os"
@@ -25,34 +27,192 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 )
 
+// fields tagged with config:"secret" are masked when they are set by environment variables
+// job_log_max_age and user_operation_log_max_age are only supported by the postgres backend,
+// the camunda rest api has no endpoint to remove these entries
 type ConfigStruct struct {
-	EngineUrl     string `json:"engine_url"`
-	MaxAge        string `json:"max_age"`
-	BatchSize     int    `json:"batch_size"`
-	FilterLocally bool   `json:"filter_locally"`
-	Location      string `json:"location"`
-	Interval      string `json:"interval"`
-	Debug         bool   `json:"debug"`
+	EngineUrl                   string                  `json:"engine_url"`
+	EngineRateLimit             float64                 `json:"engine_rate_limit"`
+	EngineMinRateLimit          float64                 `json:"engine_min_rate_limit"`
+	EngineAdaptiveThrottling    bool                    `json:"engine_adaptive_throttling"`
+	EngineLatencyThreshold      string                  `json:"engine_latency_threshold"`
+	EngineMaxRetries            int                     `json:"engine_max_retries"`
+	EngineRetryBaseDelay        string                  `json:"engine_retry_base_delay"`
+	EngineRetryMaxDelay         string                  `json:"engine_retry_max_delay"`
+	EngineRequestTimeout        string                  `json:"engine_request_timeout"`
+	EngineAuth                  string                  `json:"engine_auth"`
+	EngineUser                  string                  `json:"engine_user"`
+	EnginePassword              string                  `json:"engine_password" config:"secret"`
+	EnginePasswordFile          string                  `json:"engine_password_file"`
+	EngineToken                 string                  `json:"engine_token" config:"secret"`
+	EngineTokenFile             string                  `json:"engine_token_file"`
+	EngineTokenUrl              string                  `json:"engine_token_url"`
+	EngineClientId              string                  `json:"engine_client_id"`
+	EngineClientSecret          string                  `json:"engine_client_secret" config:"secret"`
+	EngineClientSecretFile      string                  `json:"engine_client_secret_file"`
+	EngineScopes                []string                `json:"engine_scopes"`
+	EngineCaFile                string                  `json:"engine_ca_file"`
+	EngineClientCertFile        string                  `json:"engine_client_cert_file"`
+	EngineClientKeyFile         string                  `json:"engine_client_key_file"`
+	EngineTlsMinVersion         string                  `json:"engine_tls_min_version"`
+	EngineTlsInsecureSkipVerify bool                    `json:"engine_tls_insecure_skip_verify"`
+	Backend                     string                  `json:"backend"`
+	PostgresConnStr             string                  `json:"postgres_conn_str" config:"secret"`
+	PostgresTimeZone            string                  `json:"postgres_time_zone"`
+	MaxAge                      string                  `json:"max_age"`
+	RetentionRules              []RetentionRule         `json:"retention_rules"`
+	TenantRetentionRules        []TenantRetentionRule   `json:"tenant_retention_rules"`
+	ExemptTenants               []string                `json:"exempt_tenants"`
+	DecisionMaxAge              string                  `json:"decision_max_age"`
+	DecisionRetentionRules      []DecisionRetentionRule `json:"decision_retention_rules"`
+	BatchSize                   int                     `json:"batch_size"`
+	FilterLocally               bool                    `json:"filter_locally"`
+	Location                    string                  `json:"location"`
+	Interval                    string                  `json:"interval"`
+	Schedule                    string                  `json:"schedule"`
+	SkipStartupRun              bool                    `json:"skip_startup_run"`
+	MaintenanceWindows          []MaintenanceWindow     `json:"maintenance_windows"`
+	DeleteStrategy              string                  `json:"delete_strategy"`
+	DeleteConcurrency           int                     `json:"delete_concurrency"`
+	OrphanCleanup               bool                    `json:"orphan_cleanup"`
+	OrphanHistoryTypes          []string                `json:"orphan_history_types"`
+	HistoricBatchMaxAge         string                  `json:"historic_batch_max_age"`
+	JobLogMaxAge                string                  `json:"job_log_max_age"`
+	UserOperationLogMaxAge      string                  `json:"user_operation_log_max_age"`
+	ArchiveSink                 string                  `json:"archive_sink"`
+	ArchiveDir                  string                  `json:"archive_dir"`
+	ArchiveMaxFileSize          int64                   `json:"archive_max_file_size"`
+	ArchiveGzip                 bool                    `json:"archive_gzip"`
+	ArchiveS3Endpoint           string                  `json:"archive_s3_endpoint"`
+	ArchiveS3Bucket             string                  `json:"archive_s3_bucket"`
+	ArchiveS3Region             string                  `json:"archive_s3_region"`
+	ArchiveS3AccessKey          string                  `json:"archive_s3_access_key" config:"secret"`
+	ArchiveS3SecretKey          string                  `json:"archive_s3_secret_key" config:"secret"`
+	ArchiveS3Prefix             string                  `json:"archive_s3_prefix"`
+	DryRun                      bool                    `json:"dry_run"`
+	DryRunListIds               bool                    `json:"dry_run_list_ids"`
+	ApiPort                     string                  `json:"api_port"`
+	Debug                       bool                    `json:"debug"`
+}
+
+// RetentionRule overwrites MaxAge for all process instances of the given process definition key
+// instances without a matching rule fall back to MaxAge
+type RetentionRule struct {
+	ProcessDefinitionKey string `json:"process_definition_key"`
+	MaxAge               string `json:"max_age"`
+}
+
+// DecisionRetentionRule sets the max age of historic decision instances of the given decision definition key
+// instances without a matching rule fall back to DecisionMaxAge; decision instances are not removed if neither is set
+type DecisionRetentionRule struct {
+	DecisionDefinitionKey string `json:"decision_definition_key"`
+	MaxAge                string `json:"max_age"`
+}
+
+// MaintenanceWindow is a time range in Location in which cleanups may run
+// if End is not after Start, the window ends on the following day
+// an empty Weekdays list allows every day; otherwise the window has to start on one of the listed days (e.g. "mon", "tuesday")
+type MaintenanceWindow struct {
+	Weekdays []string `json:"weekdays"`
+	Start    string   `json:"start"`
+	End      string   `json:"end"`
+}
+
+// TenantRetentionRule overwrites MaxAge and all RetentionRules for the process instances of the given tenant
+// history of tenants listed in ConfigStruct.ExemptTenants is never removed
+type TenantRetentionRule struct {
+	TenantId string `json:"tenant_id"`
+	MaxAge   string `json:"max_age"`
 }
 
 type Config = *ConfigStruct
 
+// values of the enumerated settings; the packages that implement them refer to these constants
+const (
+	BackendRest     = "rest"
+	BackendPostgres = "postgres"
+
+	DeleteStrategySingle = "single"
+	DeleteStrategyBatch  = "batch"
+
+	AuthNone   = "none"
+	AuthBasic  = "basic"
+	AuthBearer = "bearer"
+	AuthOAuth2 = "oauth2"
+
+	ArchiveSinkFile = "file"
+	ArchiveSinkS3   = "s3"
+)
+
+// TlsVersions maps the values of engine_tls_min_version to tls versions
+var TlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// Weekdays maps the day names of maintenance windows to weekdays
+var Weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "sunday": time.Sunday,
+	"mon": time.Monday, "monday": time.Monday,
+	"tue": time.Tuesday, "tuesday": time.Tuesday,
+	"wed": time.Wednesday, "wednesday": time.Wednesday,
+	"thu": time.Thursday, "thursday": time.Thursday,
+	"fri": time.Friday, "friday": time.Friday,
+	"sat": time.Saturday, "saturday": time.Saturday,
+}
+
+// ParseWeekday accepts short and long english day names, case-insensitive
+func ParseWeekday(value string) (time.Weekday, error) {
+	weekday, ok := Weekdays[strings.ToLower(strings.TrimSpace(value))]
+	if !ok {
+		return 0, fmt.Errorf("unknown weekday %v", value)
+	}
+	return weekday, nil
+}
+
+// ParseTimeOfDay parses HH:MM and returns the duration since midnight
+func ParseTimeOfDay(value string) (time.Duration, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("expect HH:MM, got %q", value)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// Load reads the json, yaml or toml config file, applies the environment variables and validates the result
+// the format is detected by the file extension
+// unknown fields, unparsable environment variables and invalid values are reported together
 func Load(location string) (config Config, err error) {
-	file, error := os.Open(location)
-	if error != nil {
-		log.Println("error on config load: ", error)
-		return config, error
+	log.Println("load config from", location)
+	data, err := os.ReadFile(location)
+	if err != nil {
+		log.Println("error on config load: ", err)
+		return config, err
+	}
+	data, err = toJson(location, data)
+	if err != nil {
+		log.Println("invalid config file: ", err)
+		return config, err
 	}
-	decoder := json.NewDecoder(file)
-	error = decoder.Decode(&config)
-	if error != nil {
-		log.Println("invalid config json: ", error)
-		return config, error
+	var raw interface{}
+	err = json.Unmarshal(data, &raw)
+	if err != nil {
+		log.Println("invalid config json: ", err)
+		return config, err
 	}
-	HandleEnvironmentVars(config)
-	return config, nil
+	errs := unknownFields(raw, reflect.TypeOf(ConfigStruct{}), "")
+	config = &ConfigStruct{}
+	err = json.Unmarshal(data, config)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("%w: %w", ErrInvalidConfig, err))
+	}
+	errs = append(errs, HandleEnvironmentVars(config), Validate(config))
+	return config, errors.Join(errs...)
 }
 
 var camel = regexp.MustCompile("(^[^A-Z]*|[A-Z]*)([A-Z][^A-Z]+|$)")
@@ -71,55 +231,184 @@ func fieldNameToEnvName(s string) string {
 }
 
 // preparations for docker
-func HandleEnvironmentVars(config Config) {
-	configValue := reflect.Indirect(reflect.ValueOf(config))
-	configType := configValue.Type()
-	for index := 0; index < configType.NumField(); index++ {
-		fieldName := configType.Field(index).Name
-		envName := fieldNameToEnvName(fieldName)
-		envValue := os.Getenv(envName)
-		if envValue != "" {
-			fmt.Println("use environment variable: ", envName, " = ", envValue)
-			if configValue.FieldByName(fieldName).Kind() == reflect.Int64 {
-				i, _ := strconv.ParseInt(envValue, 10, 64)
-				configValue.FieldByName(fieldName).SetInt(i)
-			}
-			if configValue.FieldByName(fieldName).Kind() == reflect.Int32 {
-				i, _ := strconv.ParseInt(envValue, 10, 64)
-				configValue.FieldByName(fieldName).SetInt(i)
-			}
-			if configValue.FieldByName(fieldName).Kind() == reflect.Int {
-				i, _ := strconv.ParseInt(envValue, 10, 64)
-				configValue.FieldByName(fieldName).SetInt(i)
-			}
-			if configValue.FieldByName(fieldName).Kind() == reflect.Float64 {
-				f, _ := strconv.ParseFloat(envValue, 64)
-				configValue.FieldByName(fieldName).SetFloat(f)
-			}
-			if configValue.FieldByName(fieldName).Kind() == reflect.String {
-				configValue.FieldByName(fieldName).SetString(envValue)
-			}
-			if configValue.FieldByName(fieldName).Kind() == reflect.Bool {
-				b, _ := strconv.ParseBool(envValue)
-				configValue.FieldByName(fieldName).SetBool(b)
+// nested struct fields and slice elements are addressed by appending their env names or indexes, e.g. RETENTION_RULES_0_MAX_AGE
+// string fields may be read from the file named in <NAME>_FILE, unless the config has its own *_FILE field for the value
+// returns one error per environment variable that does not match the type of its field
+func HandleEnvironmentVars(config Config) error {
+	return errors.Join(handleStructEnv(reflect.Indirect(reflect.ValueOf(config)), "")...)
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+var envIndex = regexp.MustCompile(`^(\d+)(_|$)`)
+
+func handleStructEnv(value reflect.Value, prefix string) (errs []error) {
+	valueType := value.Type()
+	envNames := map[string]bool{}
+	for index := 0; index < valueType.NumField(); index++ {
+		envNames[prefix+fieldNameToEnvName(valueType.Field(index).Name)] = true
+	}
+	for index := 0; index < valueType.NumField(); index++ {
+		field := valueType.Field(index)
+		if !field.IsExported() {
+			continue
+		}
+		envName := prefix + fieldNameToEnvName(field.Name)
+		secret := field.Tag.Get("config") == "secret"
+		errs = append(errs, handleFieldEnv(value.Field(index), envName, secret, !envNames[envName+"_FILE"])...)
+	}
+	return errs
+}
+
+func handleFieldEnv(field reflect.Value, envName string, secret bool, allowFile bool) (errs []error) {
+	if field.Kind() == reflect.Struct {
+		return handleStructEnv(field, envName+"_")
+	}
+	envValue := os.Getenv(envName)
+	fileName := ""
+	if allowFile && field.Kind() == reflect.String {
+		fileName = os.Getenv(envName + "_FILE")
+	}
+	switch {
+	case envValue != "" && fileName != "":
+		errs = append(errs, fmt.Errorf("%w: expect either environment variable %v or %v_FILE, not both", ErrInvalidConfig, envName, envName))
+	case envValue != "":
+		log.Println("use environment variable", envName, "=", maskSecret(envValue, secret))
+		err := setEnvValue(field, envValue)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%w: environment variable %v: %w", ErrInvalidConfig, envName, err))
+		}
+	case fileName != "":
+		content, err := os.ReadFile(fileName)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%w: environment variable %v_FILE: %w", ErrInvalidConfig, envName, err))
+			break
+		}
+		log.Println("use file", fileName, "from environment variable", envName+"_FILE")
+		field.SetString(strings.TrimSpace(string(content)))
+	}
+	if field.Kind() == reflect.Slice {
+		errs = append(errs, handleSliceElementsEnv(field, envName, secret)...)
+	}
+	return errs
+}
+
+// handleSliceElementsEnv sets single elements from <NAME>_<INDEX> and <NAME>_<INDEX>_<FIELD> variables
+// the slice is extended to the highest index that is found; indexes beyond the current length
+// have to be contiguous, because a gap would silently create zero value elements
+func handleSliceElementsEnv(field reflect.Value, envName string, secret bool) (errs []error) {
+	prefix := envName + "_"
+	maxIndex := -1
+	indexes := map[int]string{}
+	for _, env := range os.Environ() {
+		key, _, _ := strings.Cut(env, "=")
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		match := envIndex.FindStringSubmatch(strings.TrimPrefix(key, prefix))
+		if match == nil {
+			continue
+		}
+		index, err := strconv.Atoi(match[1])
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%w: environment variable %v: %w", ErrInvalidConfig, key, err))
+			continue
+		}
+		indexes[index] = key
+		maxIndex = max(maxIndex, index)
+	}
+	if maxIndex < 0 {
+		return errs
+	}
+	for index := field.Len(); index < maxIndex; index++ {
+		if _, ok := indexes[index]; !ok {
+			errs = append(errs, fmt.Errorf("%w: environment variable %v: missing element %v%v", ErrInvalidConfig, indexes[maxIndex], prefix, index))
+			maxIndex = field.Len() - 1
+			break
+		}
+	}
+	if field.Len() <= maxIndex {
+		extended := reflect.MakeSlice(field.Type(), maxIndex+1, maxIndex+1)
+		reflect.Copy(extended, field)
+		field.Set(extended)
+	}
+	for index := 0; index <= maxIndex; index++ {
+		errs = append(errs, handleFieldEnv(field.Index(index), prefix+strconv.Itoa(index), secret, true)...)
+	}
+	return errs
+}
+
+// setEnvValue parses envValue according to the type of field
+// lists are comma separated or json encoded; lists of structs have to be json encoded
+func setEnvValue(field reflect.Value, envValue string) error {
+	if field.Type() == durationType {
+		d, err := time.ParseDuration(envValue)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(d))
+		return nil
+	}
+	switch field.Kind() {
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(envValue, 10, 64)
+		if err != nil {
+			return err
+		}
+		field.SetInt(i)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(envValue, 64)
+		if err != nil {
+			return err
+		}
+		field.SetFloat(f)
+	case reflect.String:
+		field.SetString(envValue)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(envValue)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Slice:
+		elemKind := field.Type().Elem().Kind()
+		if strings.HasPrefix(strings.TrimSpace(envValue), "[") || elemKind == reflect.Struct || elemKind == reflect.Slice || elemKind == reflect.Map {
+			val := reflect.New(field.Type())
+			err := json.Unmarshal([]byte(envValue), val.Interface())
+			if err != nil {
+				return fmt.Errorf("unable to parse json list: %w", err)
 			}
-			if configValue.FieldByName(fieldName).Kind() == reflect.Slice {
-				val := []string{}
-				for _, element := range strings.Split(envValue, ",") {
-					val = append(val, strings.TrimSpace(element))
-				}
-				configValue.FieldByName(fieldName).Set(reflect.ValueOf(val))
+			field.Set(val.Elem())
+			return nil
+		}
+		elements := strings.Split(envValue, ",")
+		val := reflect.MakeSlice(field.Type(), len(elements), len(elements))
+		for index, element := range elements {
+			err := setEnvValue(val.Index(index), strings.TrimSpace(element))
+			if err != nil {
+				return fmt.Errorf("element %v: %w", index, err)
 			}
-			if configValue.FieldByName(fieldName).Kind() == reflect.Map {
-				value := map[string]string{}
-				for _, element := range strings.Split(envValue, ",") {
-					keyVal := strings.Split(element, ":")
-					key := strings.TrimSpace(keyVal[0])
-					val := strings.TrimSpace(keyVal[1])
-					value[key] = val
-				}
-				configValue.FieldByName(fieldName).Set(reflect.ValueOf(value))
+		}
+		field.Set(val)
+	case reflect.Map:
+		value := map[string]string{}
+		for _, element := range strings.Split(envValue, ",") {
+			key, val, found := strings.Cut(element, ":")
+			if !found {
+				return fmt.Errorf("expect key:value, got %q", element)
 			}
+			value[strings.TrimSpace(key)] = strings.TrimSpace(val)
 		}
+		field.Set(reflect.ValueOf(value))
+	default:
+		return fmt.Errorf("unsupported type %v", field.Type())
+	}
+	return nil
+}
+
+func maskSecret(value string, secret bool) string {
+	if secret {
+		return "***"
 	}
+	return value
 }
@@ -0,0 +1,137 @@
+/*
+ * Copyright 2020 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package configuration
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidDuration = errors.New("invalid duration")
+
+// Duration is a retention period with calendar and clock parts
+// calendar parts are resolved in a location like time.AddDate: one day keeps the wall clock across daylight saving changes
+type Duration struct {
+	Years  int
+	Months int
+	Days   int
+	Clock  time.Duration
+	text   string
+}
+
+// unit values are parsed in order of appearance; "mo" has to be checked before "m" and "ms"
+var durationElement = regexp.MustCompile(`^(\d+(?:\.\d+)?)(mo|ms|us|µs|ns|y|w|d|h|m|s)`)
+
+var isoDuration = regexp.MustCompile(`^P(?:(\d+)Y)?(?:(\d+)M)?(?:(\d+)W)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+(?:[.,]\d+)?)S)?)?$`)
+
+// ParseDuration accepts the units of time.ParseDuration plus d (day), w (week), mo (month) and y (year), e.g. "7d", "1y6mo" or "36h",
+// and ISO-8601 durations like "P30D", "P1Y2M" or "PT12H"
+// calendar units have to be integers
+func ParseDuration(text string) (result Duration, err error) {
+	text = strings.TrimSpace(text)
+	result.text = text
+	if text == "" {
+		return result, fmt.Errorf("%w: empty duration", ErrInvalidDuration)
+	}
+	if strings.HasPrefix(strings.ToUpper(text), "P") {
+		return parseIsoDuration(result, strings.ToUpper(text))
+	}
+	if text == "0" {
+		return result, nil
+	}
+	for rest := text; rest != ""; {
+		match := durationElement.FindStringSubmatch(rest)
+		if match == nil {
+			return result, fmt.Errorf("%w: %q", ErrInvalidDuration, text)
+		}
+		rest = rest[len(match[0]):]
+		value, unit := match[1], match[2]
+		switch unit {
+		case "y", "mo", "w", "d":
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return result, fmt.Errorf("%w: %q, expect integer %v", ErrInvalidDuration, text, unit)
+			}
+			switch unit {
+			case "y":
+				result.Years = result.Years + n
+			case "mo":
+				result.Months = result.Months + n
+			case "w":
+				result.Days = result.Days + 7*n
+			case "d":
+				result.Days = result.Days + n
+			}
+		default:
+			clock, err := time.ParseDuration(value + unit)
+			if err != nil {
+				return result, fmt.Errorf("%w: %q", ErrInvalidDuration, text)
+			}
+			result.Clock = result.Clock + clock
+		}
+	}
+	return result, nil
+}
+
+func parseIsoDuration(result Duration, text string) (Duration, error) {
+	match := isoDuration.FindStringSubmatch(text)
+	if match == nil || text == "P" || strings.HasSuffix(text, "T") {
+		return result, fmt.Errorf("%w: %q", ErrInvalidDuration, text)
+	}
+	number := func(s string) int {
+		n, _ := strconv.Atoi(s) //the regex ensures digits; empty groups are 0
+		return n
+	}
+	result.Years = number(match[1])
+	result.Months = number(match[2])
+	result.Days = 7*number(match[3]) + number(match[4])
+	result.Clock = time.Duration(number(match[5]))*time.Hour + time.Duration(number(match[6]))*time.Minute
+	if match[7] != "" {
+		seconds, err := strconv.ParseFloat(strings.Replace(match[7], ",", ".", 1), 64)
+		if err != nil {
+			return result, fmt.Errorf("%w: %q", ErrInvalidDuration, text)
+		}
+		result.Clock = result.Clock + time.Duration(seconds*float64(time.Second))
+	}
+	return result, nil
+}
+
+// Before returns the time that lies the duration before t, with calendar parts evaluated in location
+func (this Duration) Before(t time.Time, location *time.Location) time.Time {
+	return t.In(location).AddDate(-this.Years, -this.Months, -this.Days).Add(-this.Clock)
+}
+
+// After returns the time that lies the duration after t, with calendar parts evaluated in location
+func (this Duration) After(t time.Time, location *time.Location) time.Time {
+	return t.In(location).AddDate(this.Years, this.Months, this.Days).Add(this.Clock)
+}
+
+func (this Duration) IsZero() bool {
+	return this.Years == 0 && this.Months == 0 && this.Days == 0 && this.Clock == 0
+}
+
+// String returns the parsed text
+func (this Duration) String() string {
+	if this.text != "" {
+		return this.text
+	}
+	return fmt.Sprintf("%vy%vmo%vd%v", this.Years, this.Months, this.Days, this.Clock.String())
+}
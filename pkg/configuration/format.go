@@ -0,0 +1,180 @@
+/*
+ * Copyright 2020 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package configuration
+
+import (
+	"encoding/json"
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// toJson converts yaml (.yaml, .yml) and toml (.toml) config files to json
+// so that every format uses the json tags of ConfigStruct and the same unknown field check
+// unquoted numbers and booleans are converted to strings where ConfigStruct expects a string, e.g. api_port: 8080
+// other files are expected to be json
+func toJson(location string, data []byte) ([]byte, error) {
+	var value interface{}
+	switch strings.ToLower(filepath.Ext(location)) {
+	case ".yaml", ".yml":
+		document := yaml.Node{}
+		err := yaml.Unmarshal(data, &document)
+		if err != nil {
+			return nil, err
+		}
+		if len(document.Content) > 0 {
+			value, err = yamlValue(document.Content[0], reflect.TypeOf(ConfigStruct{}))
+			if err != nil {
+				return nil, err
+			}
+		}
+	case ".toml":
+		table := map[string]interface{}{}
+		_, err := toml.Decode(string(data), &table)
+		if err != nil {
+			return nil, err
+		}
+		value = tomlValue(table, reflect.TypeOf(ConfigStruct{}))
+	default:
+		return data, nil
+	}
+	return json.Marshal(value)
+}
+
+// yamlValue decodes node like yaml.Unmarshal, but keeps the text of scalars that are set to string fields of t
+// e.g. engine_tls_min_version: 1.0 would be the number 1 otherwise; t is nil for unknown fields
+func yamlValue(node *yaml.Node, t reflect.Type) (result interface{}, err error) {
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if node.Kind == yaml.AliasNode {
+		return yamlValue(node.Alias, t)
+	}
+	switch {
+	case t == nil:
+	case node.Kind == yaml.ScalarNode && t.Kind() == reflect.String:
+		switch node.ShortTag() {
+		case "!!int", "!!float", "!!bool":
+			return node.Value, nil
+		}
+	case node.Kind == yaml.MappingNode && t.Kind() == reflect.Struct && !hasMergeKey(node):
+		fields := fieldTypes(t)
+		object := map[string]interface{}{}
+		for i := 0; i+1 < len(node.Content); i = i + 2 {
+			key := node.Content[i].Value
+			object[key], err = yamlValue(node.Content[i+1], fields[key])
+			if err != nil {
+				return nil, err
+			}
+		}
+		return object, nil
+	case node.Kind == yaml.SequenceNode && t.Kind() == reflect.Slice:
+		list := []interface{}{}
+		for _, element := range node.Content {
+			value, err := yamlValue(element, t.Elem())
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, value)
+		}
+		return list, nil
+	}
+	err = node.Decode(&result)
+	return result, err
+}
+
+// merge keys (<<: *anchor) are resolved by yaml.Node.Decode only
+func hasMergeKey(node *yaml.Node) bool {
+	for i := 0; i < len(node.Content); i = i + 2 {
+		if node.Content[i].ShortTag() == "!!merge" {
+			return true
+		}
+	}
+	return false
+}
+
+// tomlValue converts numbers and booleans of value to strings where t expects a string
+// floats keep their decimal point, so that engine_tls_min_version = 1.0 stays "1.0"; t is nil for unknown fields
+func tomlValue(value interface{}, t reflect.Type) interface{} {
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == nil {
+		return value
+	}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if t.Kind() != reflect.Struct {
+			return value
+		}
+		fields := fieldTypes(t)
+		object := map[string]interface{}{}
+		for key, element := range v {
+			object[key] = tomlValue(element, fields[key])
+		}
+		return object
+	case []map[string]interface{}:
+		if t.Kind() != reflect.Slice {
+			return value
+		}
+		list := []interface{}{}
+		for _, element := range v {
+			list = append(list, tomlValue(element, t.Elem()))
+		}
+		return list
+	case []interface{}:
+		if t.Kind() != reflect.Slice {
+			return value
+		}
+		list := []interface{}{}
+		for _, element := range v {
+			list = append(list, tomlValue(element, t.Elem()))
+		}
+		return list
+	}
+	if t.Kind() != reflect.String {
+		return value
+	}
+	switch v := value.(type) {
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		text := strconv.FormatFloat(v, 'f', -1, 64)
+		if !strings.Contains(text, ".") {
+			text = text + ".0"
+		}
+		return text
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	return value
+}
+
+// fieldTypes returns the field types of the struct t by their json names
+func fieldTypes(t reflect.Type) map[string]reflect.Type {
+	result := map[string]reflect.Type{}
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			result[name] = t.Field(i).Type
+		}
+	}
+	return result
+}
@@ -0,0 +1,268 @@
+/*
+ * Copyright 2020 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package configuration
+
+import (
+	"errors"
+	"fmt"
+	"github.com/robfig/cron/v3"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidConfig = errors.New("invalid config")
+
+// Validate checks the config values that would otherwise only fail during the first cleanup
+// every problem is reported; the result joins one error per problem
+func Validate(config Config) error {
+	errs := []error{}
+	check := func(field string, err error) {
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%w %v: %w", ErrInvalidConfig, field, err))
+		}
+	}
+
+	check("backend", validateOneOf(config.Backend, "", BackendRest, BackendPostgres))
+	//the postgres backend does not use the engine url
+	if config.Backend != BackendPostgres || config.EngineUrl != "" {
+		check("engine_url", validateUrl(config.EngineUrl))
+	}
+	if config.EngineTokenUrl != "" || config.EngineAuth == AuthOAuth2 {
+		check("engine_token_url", validateUrl(config.EngineTokenUrl))
+	}
+	check("engine_auth", validateOneOf(config.EngineAuth, "", AuthNone, AuthBasic, AuthBearer, AuthOAuth2))
+	switch config.EngineAuth {
+	case AuthBasic:
+		check("engine_user", validateRequired(config.EngineUser))
+		check("engine_password", validateRequired(config.EnginePassword, config.EnginePasswordFile))
+	case AuthBearer:
+		check("engine_token", validateRequired(config.EngineToken, config.EngineTokenFile))
+	case AuthOAuth2:
+		check("engine_client_id", validateRequired(config.EngineClientId))
+		check("engine_client_secret", validateRequired(config.EngineClientSecret, config.EngineClientSecretFile))
+	}
+	if _, ok := TlsVersions[config.EngineTlsMinVersion]; !ok && config.EngineTlsMinVersion != "" {
+		check("engine_tls_min_version", fmt.Errorf("expect one of 1.0, 1.1, 1.2, 1.3, got %q", config.EngineTlsMinVersion))
+	}
+	check("delete_strategy", validateOneOf(config.DeleteStrategy, "", DeleteStrategySingle, DeleteStrategyBatch))
+	check("archive_sink", validateOneOf(config.ArchiveSink, "", "-", ArchiveSinkFile, ArchiveSinkS3))
+	switch config.ArchiveSink {
+	case ArchiveSinkFile:
+		check("archive_dir", validateRequired(config.ArchiveDir))
+	case ArchiveSinkS3:
+		check("archive_s3_endpoint", validateUrl(config.ArchiveS3Endpoint))
+		check("archive_s3_bucket", validateRequired(config.ArchiveS3Bucket))
+	}
+	if config.BatchSize <= 0 {
+		check("batch_size", fmt.Errorf("expect value > 0, got %v", config.BatchSize))
+	}
+	_, err := time.LoadLocation(config.Location)
+	check("location", err)
+
+	check("max_age", validateDuration(config.MaxAge))
+	keys := map[string]bool{}
+	for i, rule := range config.RetentionRules {
+		check(fmt.Sprintf("retention_rules[%v].process_definition_key", i), validateUnique(keys, rule.ProcessDefinitionKey))
+		check(fmt.Sprintf("retention_rules[%v].max_age", i), validateDuration(rule.MaxAge))
+	}
+	//a tenant is either exempt or has one retention rule
+	tenants := map[string]bool{}
+	for i, tenant := range config.ExemptTenants {
+		check(fmt.Sprintf("exempt_tenants[%v]", i), validateUnique(tenants, tenant))
+	}
+	for i, rule := range config.TenantRetentionRules {
+		check(fmt.Sprintf("tenant_retention_rules[%v].tenant_id", i), validateUnique(tenants, rule.TenantId))
+		check(fmt.Sprintf("tenant_retention_rules[%v].max_age", i), validateDuration(rule.MaxAge))
+	}
+	decisionKeys := map[string]bool{}
+	for i, rule := range config.DecisionRetentionRules {
+		check(fmt.Sprintf("decision_retention_rules[%v].decision_definition_key", i), validateUnique(decisionKeys, rule.DecisionDefinitionKey))
+		check(fmt.Sprintf("decision_retention_rules[%v].max_age", i), validateDuration(rule.MaxAge))
+	}
+	check("decision_max_age", validateOptionalDuration(config.DecisionMaxAge))
+	check("historic_batch_max_age", validateOptionalDuration(config.HistoricBatchMaxAge))
+	check("job_log_max_age", validateOptionalDuration(config.JobLogMaxAge))
+	check("job_log_max_age", validatePostgresOnly(config.Backend, config.JobLogMaxAge))
+	check("user_operation_log_max_age", validateOptionalDuration(config.UserOperationLogMaxAge))
+	check("user_operation_log_max_age", validatePostgresOnly(config.Backend, config.UserOperationLogMaxAge))
+	check("interval", validateInterval(config.Interval))
+	check("schedule", validateSchedule(config.Interval, config.Schedule))
+	for i, window := range config.MaintenanceWindows {
+		for j, day := range window.Weekdays {
+			_, err = ParseWeekday(day)
+			check(fmt.Sprintf("maintenance_windows[%v].weekdays[%v]", i, j), err)
+		}
+		_, err = ParseTimeOfDay(window.Start)
+		check(fmt.Sprintf("maintenance_windows[%v].start", i), err)
+		_, err = ParseTimeOfDay(window.End)
+		check(fmt.Sprintf("maintenance_windows[%v].end", i), err)
+	}
+
+	check("engine_latency_threshold", validateClockDuration(config.EngineLatencyThreshold))
+	check("engine_retry_base_delay", validateClockDuration(config.EngineRetryBaseDelay))
+	check("engine_retry_max_delay", validateClockDuration(config.EngineRetryMaxDelay))
+	check("engine_request_timeout", validateClockDuration(config.EngineRequestTimeout))
+
+	return errors.Join(errs...)
+}
+
+func validateUrl(value string) error {
+	if value == "" {
+		return errors.New("missing value")
+	}
+	parsed, err := url.Parse(value)
+	if err != nil {
+		return err
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return fmt.Errorf("expect http or https url, got %q", value)
+	}
+	if parsed.Host == "" {
+		return fmt.Errorf("missing host in %q", value)
+	}
+	return nil
+}
+
+func validateOneOf(value string, allowed ...string) error {
+	for _, candidate := range allowed {
+		if value == candidate {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown value %q", value)
+}
+
+// validatePostgresOnly rejects optional settings that the rest backend can not apply; "" and "-" disable them
+func validatePostgresOnly(backend string, value string) error {
+	if backend == BackendPostgres || value == "" || value == "-" {
+		return nil
+	}
+	return errors.New("only supported by the postgres backend")
+}
+
+// validateRequired expects at least one non empty value, e.g. a secret or its *_file
+func validateRequired(values ...string) error {
+	for _, value := range values {
+		if value != "" {
+			return nil
+		}
+	}
+	return errors.New("missing value")
+}
+
+// validateUnique expects a non empty value that is not in known and adds it to known
+func validateUnique(known map[string]bool, value string) error {
+	if value == "" {
+		return errors.New("missing value")
+	}
+	if known[value] {
+		return fmt.Errorf("duplicate value %q", value)
+	}
+	known[value] = true
+	return nil
+}
+
+func validateInterval(value string) error {
+	if value == "" || value == "-" {
+		return nil
+	}
+	interval, err := ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	if interval.IsZero() {
+		return errors.New("expect interval > 0")
+	}
+	return nil
+}
+
+// validateSchedule expects a standard 5 field cron expression; interval and schedule exclude each other
+func validateSchedule(interval string, schedule string) error {
+	if schedule == "" || schedule == "-" {
+		return nil
+	}
+	if interval != "" && interval != "-" {
+		return errors.New("expect either interval or schedule, not both")
+	}
+	_, err := cron.ParseStandard(schedule)
+	return err
+}
+
+func validateDuration(value string) error {
+	_, err := ParseDuration(value)
+	return err
+}
+
+// "" and "-" disable the optional retention
+func validateOptionalDuration(value string) error {
+	if value == "" || value == "-" {
+		return nil
+	}
+	return validateDuration(value)
+}
+
+// engine timings are plain go durations; "" uses the default
+func validateClockDuration(value string) error {
+	if value == "" {
+		return nil
+	}
+	_, err := time.ParseDuration(value)
+	return err
+}
+
+// unknownFields compares the decoded json value with the json tags of t
+// and returns one error per field that has no counterpart in t
+func unknownFields(value interface{}, t reflect.Type, path string) (errs []error) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		fields := fieldTypes(t)
+		keys := []string{}
+		for key := range object {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			element := object[key]
+			fieldType, ok := fields[key]
+			if !ok {
+				errs = append(errs, fmt.Errorf("%w: unknown field %v", ErrInvalidConfig, path+key))
+				continue
+			}
+			errs = append(errs, unknownFields(element, fieldType, path+key+".")...)
+		}
+	case reflect.Slice:
+		list, ok := value.([]interface{})
+		if !ok {
+			return nil
+		}
+		for i, element := range list {
+			errs = append(errs, unknownFields(element, t.Elem(), strings.TrimSuffix(path, ".")+"["+strconv.Itoa(i)+"].")...)
+		}
+	}
+	return errs
+}
@@ -0,0 +1,105 @@
+/*
+ * Copyright 2020 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package configuration
+
+import (
+	"context"
+	"github.com/fsnotify/fsnotify"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+// editors and config map updates produce several events for one change
+const reloadDelay = 500 * time.Millisecond
+
+// Watch loads the config file again when it changes or when the process receives SIGHUP
+// successfully loaded and validated configs are sent to the returned channel
+// invalid configs are logged and skipped, so that the receiver keeps its current config
+// the channel is closed when ctx is done
+func Watch(ctx context.Context, location string) (<-chan Config, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	//the directory is watched because editors and kubernetes config maps replace the file instead of writing to it
+	err = watcher.Add(filepath.Dir(location))
+	if err != nil {
+		watcher.Close()
+		return nil, err
+	}
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+
+	result := make(chan Config)
+	reload := func() {
+		config, err := Load(location)
+		if err != nil {
+			log.Printf("ERROR: unable to reload config, keep current config:\n%v", err)
+			return
+		}
+		select {
+		case result <- config:
+		case <-ctx.Done():
+		}
+	}
+	go func() {
+		defer close(result)
+		defer watcher.Close()
+		defer signal.Stop(hangup)
+		var delay <-chan time.Time
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if affectsConfig(event, location) {
+					delay = time.After(reloadDelay)
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				log.Println("WARNING: config watcher error", err)
+			case <-hangup:
+				log.Println("received SIGHUP, reload config")
+				delay = nil
+				reload()
+			case <-delay:
+				log.Println("config file changed, reload config")
+				delay = nil
+				reload()
+			}
+		}
+	}()
+	return result, nil
+}
+
+// kubernetes swaps the "..data" symlink of a mounted config map
+func affectsConfig(event fsnotify.Event, location string) bool {
+	if event.Op == fsnotify.Chmod {
+		return false
+	}
+	name := filepath.Base(event.Name)
+	return name == filepath.Base(location) || name == "..data"
+}
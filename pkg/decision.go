@@ -0,0 +1,89 @@
+/*
+ * Copyright 2020 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pkg
+
+import (
+	"context"
+	"github.com/SENERGY-Platform/process-history-cleanup/pkg/camunda"
+	"log"
+	"strconv"
+	"time"
+)
+
+// runDecisions removes all historic decision instances of the target that were evaluated before its max age
+// like process instances, the oldest instances are listed first so that removed instances do not have to be paged over
+// instances of excluded decision definition keys are returned by the rest api and skipped here
+func (this *cleaner) runDecisions(ctx context.Context, target decisionRetentionTarget) (err error) {
+	offset := 0
+	for {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if !this.windows.Open(time.Now()) {
+			log.Println("maintenance window closed, stop cleanup")
+			return ErrOutsideMaintenanceWindow
+		}
+		this.metrics.BatchListed()
+		candidates, err := this.engine.ListHistoricDecisionInstancesEvaluatedBefore(ctx, strconv.Itoa(this.batchSize), strconv.Itoa(offset), target.maxAge.Before(time.Now(), this.location), target.filter)
+		if err != nil {
+			return err
+		}
+		selected := camunda.HistoricDecisionInstances{}
+		for _, instance := range candidates {
+			if !target.filter.Excludes(instance) {
+				selected = append(selected, instance)
+			}
+		}
+		kept, err := this.removeDecisions(ctx, selected)
+		if err != nil {
+			return err
+		}
+		if len(candidates) != this.batchSize {
+			return nil
+		}
+		offset = offset + kept + len(candidates) - len(selected)
+	}
+}
+
+func (this *cleaner) removeDecisions(ctx context.Context, instances camunda.HistoricDecisionInstances) (kept int, err error) {
+	if this.report != nil {
+		for _, instance := range instances {
+			this.report.AddDecision(instance)
+		}
+		return len(instances), nil
+	}
+	ids := []string{}
+	for _, instance := range instances {
+		ids = append(ids, instance.Id)
+	}
+	err = this.engine.RemoveHistoricDecisionInstances(ctx, ids)
+	if ctx.Err() != nil {
+		return 0, ctx.Err()
+	}
+	for _, instance := range instances {
+		if err != nil {
+			this.metrics.DecisionDeleteFailed(instance.DecisionDefinitionKey, instance.TenantId)
+		} else {
+			this.metrics.DecisionDeleted(instance.DecisionDefinitionKey, instance.TenantId)
+		}
+	}
+	if err != nil {
+		return 0, err
+	}
+	this.deletedDecisions.Add(int64(len(ids)))
+	return 0, nil
+}
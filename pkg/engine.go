@@ -0,0 +1,59 @@
+/*
+ * Copyright 2020 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pkg
+
+import (
+	"fmt"
+	"github.com/SENERGY-Platform/process-history-cleanup/pkg/camunda"
+	"github.com/SENERGY-Platform/process-history-cleanup/pkg/configuration"
+	"github.com/SENERGY-Platform/process-history-cleanup/pkg/metrics"
+	"github.com/SENERGY-Platform/process-history-cleanup/pkg/postgres"
+)
+
+const (
+	BackendRest     = configuration.BackendRest
+	BackendPostgres = configuration.BackendPostgres
+)
+
+// NewEngine returns the configured Camunda implementation and a function to release its resources
+// the engine keeps throttle state, auth tokens and connections, it should be reused for every run with the same config
+// metrics may be nil
+func NewEngine(config configuration.Config, metrics *metrics.Metrics) (engine Camunda, close func(), err error) {
+	switch config.Backend {
+	case "", BackendRest:
+		rest := camunda.New(config)
+		rest.OnRateLimitChange(metrics.SetEngineRateLimit)
+		rest.OnRequest(metrics.Heartbeat)
+		return rest, rest.Close, nil
+	case BackendPostgres:
+		db, err := postgres.New(config)
+		if err != nil {
+			return nil, nil, err
+		}
+		return db, func() { db.Close() }, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown backend %v", config.Backend)
+	}
+}
+
+// backendName returns the configured backend with the default applied
+func backendName(config configuration.Config) string {
+	if config.Backend == "" {
+		return BackendRest
+	}
+	return config.Backend
+}
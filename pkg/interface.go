@@ -17,12 +17,38 @@
 package pkg
 
 import (
+	"context"
 	"github.com/SENERGY-Platform/process-history-cleanup/pkg/camunda"
 	"time"
 )
 
 type Camunda interface {
-	ListHistoryFinishedBefore(limit string, offset string, sortby string, sortdirection string, finished bool, before time.Time) (result camunda.HistoricProcessInstances, err error)
-	ListHistory(limit string, offset string, sortby string, sortdirection string, finished bool) (result camunda.HistoricProcessInstances, err error)
-	RemoveProcessInstanceHistory(id string) (err error)
+	ListHistoryFinishedBefore(ctx context.Context, limit string, offset string, sortby string, sortdirection string, finished bool, before time.Time, filter camunda.HistoryFilter) (result camunda.HistoricProcessInstances, err error)
+	ListHistory(ctx context.Context, limit string, offset string, sortby string, sortdirection string, finished bool, filter camunda.HistoryFilter) (result camunda.HistoricProcessInstances, err error)
+	ListHistoryCount(ctx context.Context, finished bool) (result camunda.Count, err error)
+	ListHistoryCountFinishedBefore(ctx context.Context, before time.Time, filter camunda.HistoryFilter) (result camunda.Count, err error)
+	RemoveProcessInstanceHistory(ctx context.Context, id string) (err error)
+	ListTenantIds(ctx context.Context) (result []string, err error)
+	ListHistoricActivityInstances(ctx context.Context, processInstanceId string) (result []camunda.HistoricActivityInstance, err error)
+	ListHistoricVariableInstances(ctx context.Context, processInstanceId string) (result []camunda.HistoricVariableInstance, err error)
+	ListHistoricDecisionInstancesEvaluatedBefore(ctx context.Context, limit string, offset string, before time.Time, filter camunda.DecisionFilter) (result camunda.HistoricDecisionInstances, err error)
+	RemoveHistoricDecisionInstances(ctx context.Context, ids []string) (err error)
+	ListDecisionTenantIds(ctx context.Context) (result []string, err error)
+}
+
+// BatchDeleter is implemented by engines that can remove many process instance histories with one operation
+type BatchDeleter interface {
+	RemoveProcessInstanceHistoryBatch(ctx context.Context, ids []string) (err error)
+}
+
+// OrphanCleaner is implemented by engines that can find history rows of process instances that no longer exist
+type OrphanCleaner interface {
+	OrphanHistoryTypes() []string
+	CleanupOrphans(ctx context.Context, historyType string, batchSize int, remove bool) (count int64, err error)
+}
+
+// HistoryLogCleaner is implemented by engines that can remove history which is not bound to process instances
+type HistoryLogCleaner interface {
+	HistoryLogTypes() []string
+	CleanupHistoryLog(ctx context.Context, historyType string, before time.Time, batchSize int, remove bool) (count int64, err error)
 }
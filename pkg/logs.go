@@ -0,0 +1,97 @@
+/*
+ * Copyright 2020 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"github.com/SENERGY-Platform/process-history-cleanup/pkg/camunda"
+	"github.com/SENERGY-Platform/process-history-cleanup/pkg/configuration"
+	"log"
+	"slices"
+	"time"
+)
+
+// historyLogTarget is a history type with its own max age that grows independently of process instances
+type historyLogTarget struct {
+	historyType string
+	maxAge      configuration.Duration
+}
+
+// getHistoryLogTargets returns a target for every history log type with a configured max age
+func getHistoryLogTargets(config configuration.Config) (result []historyLogTarget, err error) {
+	maxAges := []struct {
+		historyType string
+		maxAge      string
+	}{
+		{historyType: camunda.HistoryTypeBatch, maxAge: config.HistoricBatchMaxAge},
+		{historyType: camunda.HistoryTypeJobLog, maxAge: config.JobLogMaxAge},
+		{historyType: camunda.HistoryTypeUserOperation, maxAge: config.UserOperationLogMaxAge},
+	}
+	for _, element := range maxAges {
+		if element.maxAge == "" || element.maxAge == "-" {
+			continue
+		}
+		maxAge, err := configuration.ParseDuration(element.maxAge)
+		if err != nil {
+			return result, fmt.Errorf("invalid max age for %v: %w", element.historyType, err)
+		}
+		result = append(result, historyLogTarget{historyType: element.historyType, maxAge: maxAge})
+	}
+	return result, nil
+}
+
+// cleanupHistoryLogs removes historic batches, job log and user operation log entries older than their max age
+// in dry-run mode the entries are only counted
+func (this *cleaner) cleanupHistoryLogs(ctx context.Context, config configuration.Config, targets []historyLogTarget) (counts map[string]int64, err error) {
+	counts = map[string]int64{}
+	if len(targets) == 0 {
+		return counts, nil
+	}
+	engine, ok := this.engine.(HistoryLogCleaner)
+	if !ok {
+		log.Println("WARNING: backend", backendName(config), "does not support history log cleanup")
+		return counts, nil
+	}
+	supported := engine.HistoryLogTypes()
+	for _, target := range targets {
+		if !slices.Contains(supported, target.historyType) {
+			return counts, fmt.Errorf("%v entries can not be removed by the %v backend; use the postgres backend", target.historyType, backendName(config))
+		}
+		if ctx.Err() != nil {
+			return counts, ctx.Err()
+		}
+		if !this.windows.Open(time.Now()) {
+			log.Println("maintenance window closed, stop history log cleanup")
+			return counts, ErrOutsideMaintenanceWindow
+		}
+		this.metrics.Heartbeat()
+		log.Println("cleanup", target.historyType, "with max age", target.maxAge.String())
+		count, err := engine.CleanupHistoryLog(ctx, target.historyType, target.maxAge.Before(time.Now(), this.location), this.batchSize, this.report == nil)
+		counts[target.historyType] = count
+		if this.report != nil {
+			log.Printf("DRY-RUN: %v %v entries would be removed", count, target.historyType)
+		} else {
+			this.metrics.LogEntriesRemoved(target.historyType, count)
+			log.Printf("removed %v %v entries", count, target.historyType)
+		}
+		if err != nil {
+			return counts, err
+		}
+	}
+	return counts, nil
+}
@@ -0,0 +1,222 @@
+/*
+ * Copyright 2020 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// Metrics may be nil; all methods are no-ops on a nil receiver
+type Metrics struct {
+	registry          *prometheus.Registry
+	deletedInstances  *prometheus.CounterVec
+	failedDeletions   *prometheus.CounterVec
+	listedBatches     prometheus.Counter
+	runDuration       *prometheus.HistogramVec
+	lastSuccessfulRun prometheus.Gauge
+	backlog           prometheus.Gauge
+	lastActivity      prometheus.Gauge
+	engineRateLimit   prometheus.Gauge
+	removedOrphans    *prometheus.CounterVec
+	deletedDecisions  *prometheus.CounterVec
+	failedDecisions   *prometheus.CounterVec
+	removedLogEntries *prometheus.CounterVec
+	runInProgress     prometheus.Gauge
+	lastActivityTime  atomic.Int64
+	runs              atomic.Int32
+}
+
+func New() *Metrics {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(collectors.NewGoCollector(), collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+	this := &Metrics{
+		registry: reg,
+		deletedInstances: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "process_history_cleanup_deleted_instances_total",
+			Help: "number of deleted historic process instances",
+		}, []string{"process_definition_key", "tenant_id"}),
+		failedDeletions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "process_history_cleanup_failed_deletions_total",
+			Help: "number of historic process instances that could not be deleted",
+		}, []string{"process_definition_key", "tenant_id"}),
+		listedBatches: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "process_history_cleanup_listed_batches_total",
+			Help: "number of history list requests",
+		}),
+		runDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "process_history_cleanup_run_duration_seconds",
+			Help:    "duration of cleanup runs",
+			Buckets: prometheus.ExponentialBuckets(1, 4, 10),
+		}, []string{"result"}),
+		lastSuccessfulRun: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "process_history_cleanup_last_successful_run_timestamp_seconds",
+			Help: "unix timestamp of the end of the last successful cleanup run",
+		}),
+		backlog: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "process_history_cleanup_backlog_instances",
+			Help: "number of historic process instances that are older than their max age and remain after the last cleanup run",
+		}),
+		lastActivity: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "process_history_cleanup_last_activity_timestamp_seconds",
+			Help: "unix timestamp of the last scheduler tick or cleanup batch",
+		}),
+		runInProgress: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "process_history_cleanup_run_in_progress",
+			Help: "1 while a cleanup run is in progress",
+		}),
+		engineRateLimit: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "process_history_cleanup_engine_rate_limit_requests_per_second",
+			Help: "current request rate limit for the engine rest api; 0 if unlimited",
+		}),
+		removedOrphans: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "process_history_cleanup_removed_orphans_total",
+			Help: "number of removed history rows whose process instance no longer exists",
+		}, []string{"history_type"}),
+		deletedDecisions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "process_history_cleanup_deleted_decision_instances_total",
+			Help: "number of deleted historic decision instances",
+		}, []string{"decision_definition_key", "tenant_id"}),
+		failedDecisions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "process_history_cleanup_failed_decision_deletions_total",
+			Help: "number of historic decision instances that could not be deleted",
+		}, []string{"decision_definition_key", "tenant_id"}),
+		removedLogEntries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "process_history_cleanup_removed_log_entries_total",
+			Help: "number of removed historic batches, job log and user operation log entries",
+		}, []string{"history_type"}),
+	}
+	reg.MustRegister(this.deletedInstances, this.failedDeletions, this.listedBatches, this.runDuration, this.lastSuccessfulRun, this.backlog, this.lastActivity, this.runInProgress, this.engineRateLimit, this.removedOrphans, this.deletedDecisions, this.failedDecisions, this.removedLogEntries)
+	this.Heartbeat()
+	return this
+}
+
+func (this *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(this.registry, promhttp.HandlerOpts{Registry: this.registry})
+}
+
+func (this *Metrics) Deleted(processDefinitionKey string, tenantId string) {
+	if this == nil {
+		return
+	}
+	this.deletedInstances.WithLabelValues(processDefinitionKey, tenantId).Inc()
+	this.Heartbeat()
+}
+
+func (this *Metrics) DeleteFailed(processDefinitionKey string, tenantId string) {
+	if this == nil {
+		return
+	}
+	this.failedDeletions.WithLabelValues(processDefinitionKey, tenantId).Inc()
+	this.Heartbeat()
+}
+
+func (this *Metrics) DecisionDeleted(decisionDefinitionKey string, tenantId string) {
+	if this == nil {
+		return
+	}
+	this.deletedDecisions.WithLabelValues(decisionDefinitionKey, tenantId).Inc()
+	this.Heartbeat()
+}
+
+func (this *Metrics) DecisionDeleteFailed(decisionDefinitionKey string, tenantId string) {
+	if this == nil {
+		return
+	}
+	this.failedDecisions.WithLabelValues(decisionDefinitionKey, tenantId).Inc()
+	this.Heartbeat()
+}
+
+func (this *Metrics) BatchListed() {
+	if this == nil {
+		return
+	}
+	this.listedBatches.Inc()
+	this.Heartbeat()
+}
+
+// Heartbeat signals that the scheduler loop or a cleanup run is still making progress
+func (this *Metrics) Heartbeat() {
+	if this == nil {
+		return
+	}
+	now := time.Now()
+	this.lastActivityTime.Store(now.UnixNano())
+	this.lastActivity.Set(float64(now.UnixNano()) / float64(time.Second))
+}
+
+func (this *Metrics) LastActivity() time.Time {
+	if this == nil {
+		return time.Time{}
+	}
+	return time.Unix(0, this.lastActivityTime.Load())
+}
+
+// RunStarted marks a cleanup run as in progress until RunFinished is called
+func (this *Metrics) RunStarted() {
+	if this == nil {
+		return
+	}
+	this.Heartbeat()
+	this.runInProgress.Set(float64(this.runs.Add(1)))
+}
+
+func (this *Metrics) RunFinished(duration time.Duration, err error) {
+	if this == nil {
+		return
+	}
+	this.Heartbeat()
+	this.runInProgress.Set(float64(this.runs.Add(-1)))
+	if err != nil {
+		this.runDuration.WithLabelValues("error").Observe(duration.Seconds())
+		return
+	}
+	this.runDuration.WithLabelValues("success").Observe(duration.Seconds())
+	this.lastSuccessfulRun.SetToCurrentTime()
+}
+
+func (this *Metrics) SetBacklog(count int64) {
+	if this == nil {
+		return
+	}
+	this.backlog.Set(float64(count))
+}
+
+func (this *Metrics) SetEngineRateLimit(limit float64) {
+	if this == nil {
+		return
+	}
+	this.engineRateLimit.Set(limit)
+}
+
+func (this *Metrics) OrphansRemoved(historyType string, count int64) {
+	if this == nil {
+		return
+	}
+	this.removedOrphans.WithLabelValues(historyType).Add(float64(count))
+}
+
+func (this *Metrics) LogEntriesRemoved(historyType string, count int64) {
+	if this == nil {
+		return
+	}
+	this.removedLogEntries.WithLabelValues(historyType).Add(float64(count))
+}
@@ -0,0 +1,68 @@
+/*
+ * Copyright 2020 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pkg
+
+import (
+	"context"
+	"github.com/SENERGY-Platform/process-history-cleanup/pkg/configuration"
+	"log"
+	"slices"
+	"time"
+)
+
+// cleanupOrphans removes history rows whose process instance no longer exists
+// these rows are left behind by engine bugs or manual sql and are never found by the process instance cleanup
+// orphans are not archived; in dry-run mode they are only counted
+func (this *cleaner) cleanupOrphans(ctx context.Context, config configuration.Config) (counts map[string]int64, err error) {
+	counts = map[string]int64{}
+	engine, ok := this.engine.(OrphanCleaner)
+	if !ok {
+		log.Println("WARNING: backend", backendName(config), "does not support orphan cleanup")
+		return counts, nil
+	}
+	supported := engine.OrphanHistoryTypes()
+	historyTypes := config.OrphanHistoryTypes
+	if len(historyTypes) == 0 {
+		historyTypes = supported
+	}
+	for _, historyType := range historyTypes {
+		if !slices.Contains(supported, historyType) {
+			log.Println("WARNING: orphaned", historyType, "rows can not be removed by the", backendName(config), "backend; use the postgres backend")
+			continue
+		}
+		if ctx.Err() != nil {
+			return counts, ctx.Err()
+		}
+		if !this.windows.Open(time.Now()) {
+			log.Println("maintenance window closed, stop orphan cleanup")
+			return counts, ErrOutsideMaintenanceWindow
+		}
+		this.metrics.Heartbeat()
+		count, err := engine.CleanupOrphans(ctx, historyType, this.batchSize, this.report == nil)
+		counts[historyType] = count
+		if this.report != nil {
+			log.Printf("DRY-RUN: %v orphaned %v rows would be removed", count, historyType)
+		} else {
+			this.metrics.OrphansRemoved(historyType, count)
+			log.Printf("removed %v orphaned %v rows", count, historyType)
+		}
+		if err != nil {
+			return counts, err
+		}
+	}
+	return counts, nil
+}
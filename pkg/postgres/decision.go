@@ -0,0 +1,141 @@
+/*
+ * Copyright 2020 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/SENERGY-Platform/process-history-cleanup/pkg/camunda"
+	"github.com/lib/pq"
+	"log"
+	"strconv"
+	"time"
+)
+
+// $1 is the list of decision instance ids
+var deleteDecisionHistory = []string{
+	`DELETE FROM ACT_GE_BYTEARRAY WHERE ID_ IN (SELECT BYTEARRAY_ID_ FROM ACT_HI_DEC_IN WHERE DEC_INST_ID_ = ANY($1) AND BYTEARRAY_ID_ IS NOT NULL)`,
+	`DELETE FROM ACT_GE_BYTEARRAY WHERE ID_ IN (SELECT BYTEARRAY_ID_ FROM ACT_HI_DEC_OUT WHERE DEC_INST_ID_ = ANY($1) AND BYTEARRAY_ID_ IS NOT NULL)`,
+	`DELETE FROM ACT_HI_DEC_IN WHERE DEC_INST_ID_ = ANY($1)`,
+	`DELETE FROM ACT_HI_DEC_OUT WHERE DEC_INST_ID_ = ANY($1)`,
+	`DELETE FROM ACT_HI_DECINST WHERE ID_ = ANY($1)`,
+}
+
+func (this *Postgres) ListHistoricDecisionInstancesEvaluatedBefore(ctx context.Context, limit string, offset string, before time.Time, filter camunda.DecisionFilter) (result camunda.HistoricDecisionInstances, err error) {
+	limitInt, err := strconv.Atoi(limit)
+	if err != nil {
+		return result, err
+	}
+	offsetInt, err := strconv.Atoi(offset)
+	if err != nil {
+		return result, err
+	}
+	args := []interface{}{this.formatTime(before), limitInt, offsetInt}
+	query := `SELECT ID_, DEC_DEF_ID_, DEC_DEF_KEY_, COALESCE(DEC_DEF_NAME_, ''), EVAL_TIME_, COALESCE(PROC_DEF_KEY_, ''),
+	COALESCE(PROC_INST_ID_, ''), COALESCE(ROOT_PROC_INST_ID_, ''), COALESCE(TENANT_ID_, '')
+	FROM ACT_HI_DECINST WHERE EVAL_TIME_ <= $1::timestamp`
+	if len(filter.DecisionDefinitionKeyIn) > 0 {
+		args = append(args, pq.Array(filter.DecisionDefinitionKeyIn))
+		query = query + " AND DEC_DEF_KEY_ = ANY($" + strconv.Itoa(len(args)) + ")"
+	}
+	if len(filter.TenantIdIn) > 0 {
+		args = append(args, pq.Array(filter.TenantIdIn))
+		query = query + " AND TENANT_ID_ = ANY($" + strconv.Itoa(len(args)) + ")"
+	}
+	if len(filter.DecisionDefinitionKeyNotIn) > 0 {
+		args = append(args, pq.Array(filter.DecisionDefinitionKeyNotIn))
+		query = query + " AND DEC_DEF_KEY_ <> ALL($" + strconv.Itoa(len(args)) + ")"
+	}
+	if filter.WithoutTenantId {
+		query = query + " AND TENANT_ID_ IS NULL"
+	}
+	query = query + " ORDER BY EVAL_TIME_ ASC, ID_ ASC LIMIT $2 OFFSET $3"
+	rows, err := this.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return result, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		instance := camunda.HistoricDecisionInstance{}
+		var evaluationTime sql.NullTime
+		err = rows.Scan(&instance.Id, &instance.DecisionDefinitionId, &instance.DecisionDefinitionKey, &instance.DecisionDefinitionName, &evaluationTime,
+			&instance.ProcessDefinitionKey, &instance.ProcessInstanceId, &instance.RootProcessInstanceId, &instance.TenantId)
+		if err != nil {
+			return result, err
+		}
+		instance.EvaluationTime = this.formatNullTime(evaluationTime)
+		result = append(result, instance)
+	}
+	err = rows.Err()
+	if err != nil {
+		return result, err
+	}
+	if this.config.Debug {
+		log.Printf("DEBUG: read %v elements from ACT_HI_DECINST", len(result))
+	}
+	return result, nil
+}
+
+// RemoveHistoricDecisionInstances removes the given decision instances with their inputs and outputs in one transaction
+func (this *Postgres) RemoveHistoricDecisionInstances(ctx context.Context, ids []string) (err error) {
+	if len(ids) == 0 {
+		return nil
+	}
+	tx, err := this.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			rollbackErr := tx.Rollback()
+			if rollbackErr != nil && !errors.Is(rollbackErr, sql.ErrTxDone) {
+				log.Println("ERROR: unable to rollback", rollbackErr)
+			}
+		}
+	}()
+	for _, statement := range deleteDecisionHistory {
+		_, err = tx.ExecContext(ctx, statement, pq.Array(ids))
+		if err != nil {
+			return err
+		}
+	}
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+	log.Printf("deleted %v decision instance histories from database", len(ids))
+	return nil
+}
+
+// ListDecisionTenantIds returns the distinct tenant ids of all historic decision instances
+func (this *Postgres) ListDecisionTenantIds(ctx context.Context) (result []string, err error) {
+	rows, err := this.db.QueryContext(ctx, "SELECT DISTINCT TENANT_ID_ FROM ACT_HI_DECINST WHERE TENANT_ID_ IS NOT NULL")
+	if err != nil {
+		return result, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var tenant string
+		err = rows.Scan(&tenant)
+		if err != nil {
+			return result, err
+		}
+		result = append(result, tenant)
+	}
+	return result, rows.Err()
+}
@@ -0,0 +1,107 @@
+/*
+ * Copyright 2020 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	"log"
+)
+
+var ErrProcessInstanceNotFinished = errors.New("historic process instance is not finished")
+
+// bytearrays are referenced by id and have to be removed before the referencing rows
+// $1 is the list of process instance ids
+var deleteByteArrays = []string{
+	`DELETE FROM ACT_GE_BYTEARRAY WHERE ID_ IN (SELECT BYTEARRAY_ID_ FROM ACT_HI_VARINST WHERE PROC_INST_ID_ = ANY($1) AND BYTEARRAY_ID_ IS NOT NULL)`,
+	`DELETE FROM ACT_GE_BYTEARRAY WHERE ID_ IN (SELECT BYTEARRAY_ID_ FROM ACT_HI_DETAIL WHERE PROC_INST_ID_ = ANY($1) AND BYTEARRAY_ID_ IS NOT NULL)`,
+	`DELETE FROM ACT_GE_BYTEARRAY WHERE ID_ IN (SELECT CONTENT_ID_ FROM ACT_HI_ATTACHMENT WHERE PROC_INST_ID_ = ANY($1) AND CONTENT_ID_ IS NOT NULL)`,
+	`DELETE FROM ACT_GE_BYTEARRAY WHERE ID_ IN (SELECT JOB_EXCEPTION_STACK_ID_ FROM ACT_HI_JOB_LOG WHERE PROCESS_INSTANCE_ID_ = ANY($1) AND JOB_EXCEPTION_STACK_ID_ IS NOT NULL)`,
+	`DELETE FROM ACT_GE_BYTEARRAY WHERE ID_ IN (SELECT ERROR_DETAILS_ID_ FROM ACT_HI_EXT_TASK_LOG WHERE PROC_INST_ID_ = ANY($1) AND ERROR_DETAILS_ID_ IS NOT NULL)`,
+	`DELETE FROM ACT_GE_BYTEARRAY WHERE ID_ IN (SELECT i.BYTEARRAY_ID_ FROM ACT_HI_DEC_IN i JOIN ACT_HI_DECINST d ON d.ID_ = i.DEC_INST_ID_ WHERE d.PROC_INST_ID_ = ANY($1) AND i.BYTEARRAY_ID_ IS NOT NULL)`,
+	`DELETE FROM ACT_GE_BYTEARRAY WHERE ID_ IN (SELECT o.BYTEARRAY_ID_ FROM ACT_HI_DEC_OUT o JOIN ACT_HI_DECINST d ON d.ID_ = o.DEC_INST_ID_ WHERE d.PROC_INST_ID_ = ANY($1) AND o.BYTEARRAY_ID_ IS NOT NULL)`,
+}
+
+// the statements are executed in order; rows that are used to find other rows are removed last
+var deleteHistory = []string{
+	`DELETE FROM ACT_HI_DEC_IN WHERE DEC_INST_ID_ IN (SELECT ID_ FROM ACT_HI_DECINST WHERE PROC_INST_ID_ = ANY($1))`,
+	`DELETE FROM ACT_HI_DEC_OUT WHERE DEC_INST_ID_ IN (SELECT ID_ FROM ACT_HI_DECINST WHERE PROC_INST_ID_ = ANY($1))`,
+	`DELETE FROM ACT_HI_DECINST WHERE PROC_INST_ID_ = ANY($1)`,
+	`DELETE FROM ACT_HI_IDENTITYLINK WHERE TASK_ID_ IN (SELECT ID_ FROM ACT_HI_TASKINST WHERE PROC_INST_ID_ = ANY($1))`,
+	`DELETE FROM ACT_HI_COMMENT WHERE PROC_INST_ID_ = ANY($1)`,
+	`DELETE FROM ACT_HI_ATTACHMENT WHERE PROC_INST_ID_ = ANY($1)`,
+	`DELETE FROM ACT_HI_TASKINST WHERE PROC_INST_ID_ = ANY($1)`,
+	`DELETE FROM ACT_HI_DETAIL WHERE PROC_INST_ID_ = ANY($1)`,
+	`DELETE FROM ACT_HI_VARINST WHERE PROC_INST_ID_ = ANY($1)`,
+	`DELETE FROM ACT_HI_INCIDENT WHERE PROC_INST_ID_ = ANY($1)`,
+	`DELETE FROM ACT_HI_JOB_LOG WHERE PROCESS_INSTANCE_ID_ = ANY($1)`,
+	`DELETE FROM ACT_HI_EXT_TASK_LOG WHERE PROC_INST_ID_ = ANY($1)`,
+	`DELETE FROM ACT_HI_OP_LOG WHERE PROC_INST_ID_ = ANY($1)`,
+	`DELETE FROM ACT_HI_ACTINST WHERE PROC_INST_ID_ = ANY($1)`,
+	`DELETE FROM ACT_HI_PROCINST WHERE ID_ = ANY($1)`,
+}
+
+func (this *Postgres) RemoveProcessInstanceHistory(ctx context.Context, id string) (err error) {
+	return this.RemoveProcessInstanceHistoryBatch(ctx, []string{id})
+}
+
+// RemoveProcessInstanceHistoryBatch removes the given finished process instances and all dependent history in one transaction
+// the size of the transaction is bound by the number of ids, which is the configured batch size
+func (this *Postgres) RemoveProcessInstanceHistoryBatch(ctx context.Context, ids []string) (err error) {
+	if len(ids) == 0 {
+		return nil
+	}
+	tx, err := this.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			rollbackErr := tx.Rollback()
+			if rollbackErr != nil && !errors.Is(rollbackErr, sql.ErrTxDone) {
+				log.Println("ERROR: unable to rollback", rollbackErr)
+			}
+		}
+	}()
+	var unfinished int
+	err = tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM ACT_HI_PROCINST WHERE ID_ = ANY($1) AND END_TIME_ IS NULL`, pq.Array(ids)).Scan(&unfinished)
+	if err != nil {
+		return err
+	}
+	if unfinished > 0 {
+		return fmt.Errorf("%w: %v of %v instances", ErrProcessInstanceNotFinished, unfinished, len(ids))
+	}
+	for _, statement := range append(deleteByteArrays, deleteHistory...) {
+		result, err := tx.ExecContext(ctx, statement, pq.Array(ids))
+		if err != nil {
+			return err
+		}
+		if this.config.Debug {
+			affected, _ := result.RowsAffected()
+			log.Printf("DEBUG: %v rows affected by %v", affected, statement)
+		}
+	}
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+	log.Printf("deleted %v process instance histories from database", len(ids))
+	return nil
+}
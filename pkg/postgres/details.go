@@ -0,0 +1,131 @@
+/*
+ * Copyright 2020 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"github.com/SENERGY-Platform/process-history-cleanup/pkg/camunda"
+	"strings"
+	"time"
+)
+
+func (this *Postgres) ListHistoricActivityInstances(ctx context.Context, processInstanceId string) (result []camunda.HistoricActivityInstance, err error) {
+	rows, err := this.db.QueryContext(ctx, `SELECT ID_, COALESCE(PARENT_ACT_INST_ID_, ''), ACT_ID_, COALESCE(ACT_NAME_, ''), ACT_TYPE_,
+		COALESCE(PROC_DEF_KEY_, ''), PROC_DEF_ID_, PROC_INST_ID_, EXECUTION_ID_, COALESCE(TASK_ID_, ''),
+		COALESCE(CALL_PROC_INST_ID_, ''), COALESCE(CALL_CASE_INST_ID_, ''), COALESCE(ASSIGNEE_, ''),
+		START_TIME_, END_TIME_, COALESCE(DURATION_, 0), COALESCE(ACT_INST_STATE_, 0), COALESCE(TENANT_ID_, '')
+		FROM ACT_HI_ACTINST WHERE PROC_INST_ID_ = $1 ORDER BY START_TIME_ ASC, SEQUENCE_COUNTER_ ASC`, processInstanceId)
+	if err != nil {
+		return result, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		instance := camunda.HistoricActivityInstance{}
+		var startTime, endTime sql.NullTime
+		var state int
+		err = rows.Scan(&instance.Id, &instance.ParentActivityInstanceId, &instance.ActivityId, &instance.ActivityName, &instance.ActivityType,
+			&instance.ProcessDefinitionKey, &instance.ProcessDefinitionId, &instance.ProcessInstanceId, &instance.ExecutionId, &instance.TaskId,
+			&instance.CalledProcessInstanceId, &instance.CalledCaseInstanceId, &instance.Assignee,
+			&startTime, &endTime, &instance.DurationInMillis, &state, &instance.TenantId)
+		if err != nil {
+			return result, err
+		}
+		instance.StartTime = this.formatNullTime(startTime)
+		instance.EndTime = this.formatNullTime(endTime)
+		//see org.camunda.bpm.engine.impl.pvm.runtime.ActivityInstanceState
+		instance.CompleteScope = state == 1
+		instance.Canceled = state == 2
+		result = append(result, instance)
+	}
+	return result, rows.Err()
+}
+
+// ListHistoricVariableInstances returns values like the rest api with deserializeValues=false
+func (this *Postgres) ListHistoricVariableInstances(ctx context.Context, processInstanceId string) (result []camunda.HistoricVariableInstance, err error) {
+	rows, err := this.db.QueryContext(ctx, `SELECT v.ID_, v.NAME_, v.VAR_TYPE_, v.TEXT_, v.TEXT2_, v.LONG_, v.DOUBLE_, b.BYTES_,
+		COALESCE(v.PROC_DEF_KEY_, ''), COALESCE(v.PROC_DEF_ID_, ''), COALESCE(v.PROC_INST_ID_, ''), COALESCE(v.EXECUTION_ID_, ''),
+		COALESCE(v.ACT_INST_ID_, ''), COALESCE(v.TASK_ID_, ''), COALESCE(v.TENANT_ID_, ''), COALESCE(v.STATE_, ''), v.CREATE_TIME_
+		FROM ACT_HI_VARINST v LEFT JOIN ACT_GE_BYTEARRAY b ON b.ID_ = v.BYTEARRAY_ID_
+		WHERE v.PROC_INST_ID_ = $1 ORDER BY v.CREATE_TIME_ ASC`, processInstanceId)
+	if err != nil {
+		return result, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		variable := camunda.HistoricVariableInstance{}
+		var varType string
+		var text, text2 sql.NullString
+		var long sql.NullInt64
+		var double sql.NullFloat64
+		var bytes []byte
+		var createTime sql.NullTime
+		err = rows.Scan(&variable.Id, &variable.Name, &varType, &text, &text2, &long, &double, &bytes,
+			&variable.ProcessDefinitionKey, &variable.ProcessDefinitionId, &variable.ProcessInstanceId, &variable.ExecutionId,
+			&variable.ActivityInstanceId, &variable.TaskId, &variable.TenantId, &variable.State, &createTime)
+		if err != nil {
+			return result, err
+		}
+		variable.CreateTime = this.formatNullTime(createTime)
+		variable.Type, variable.Value, variable.ValueInfo = variableValue(varType, text, text2, long, double, bytes)
+		result = append(result, variable)
+	}
+	return result, rows.Err()
+}
+
+func variableValue(varType string, text sql.NullString, text2 sql.NullString, long sql.NullInt64, double sql.NullFloat64, bytes []byte) (restType string, value interface{}, valueInfo map[string]interface{}) {
+	valueInfo = map[string]interface{}{}
+	switch varType {
+	case "null":
+		return "Null", nil, valueInfo
+	case "string":
+		return "String", text.String, valueInfo
+	case "boolean":
+		return "Boolean", long.Int64 != 0, valueInfo
+	case "long", "integer", "short":
+		return strings.ToUpper(varType[:1]) + varType[1:], long.Int64, valueInfo
+	case "double":
+		return "Double", double.Float64, valueInfo
+	case "date":
+		return "Date", time.UnixMilli(long.Int64).Format(camunda.CamundaTimeFormat), valueInfo
+	case "serializable":
+		valueInfo["objectTypeName"] = text.String
+		valueInfo["serializationDataFormat"] = text2.String
+		return "Object", string(bytes), valueInfo
+	case "json", "xml":
+		return strings.ToUpper(varType[:1]) + varType[1:], string(bytes), valueInfo
+	case "file":
+		valueInfo["filename"] = text.String
+		valueInfo["mimeType"] = text2.String
+		return "File", bytes, valueInfo
+	default:
+		if len(varType) > 0 {
+			restType = strings.ToUpper(varType[:1]) + varType[1:]
+		}
+		if bytes != nil {
+			return restType, bytes, valueInfo
+		}
+		return restType, text.String, valueInfo
+	}
+}
+
+func (this *Postgres) formatNullTime(t sql.NullTime) string {
+	if !t.Valid {
+		return ""
+	}
+	return this.parseTime(t.Time).Format(camunda.CamundaTimeFormat)
+}
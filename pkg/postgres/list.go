@@ -0,0 +1,178 @@
+/*
+ * Copyright 2020 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/SENERGY-Platform/process-history-cleanup/pkg/camunda"
+	"github.com/lib/pq"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var sortColumns = map[string]string{
+	"instanceId":        "p.ID_",
+	"definitionId":      "p.PROC_DEF_ID_",
+	"definitionKey":     "p.PROC_DEF_KEY_",
+	"businessKey":       "p.BUSINESS_KEY_",
+	"startTime":         "p.START_TIME_",
+	"endTime":           "p.END_TIME_",
+	"duration":          "p.DURATION_",
+	"tenantId":          "p.TENANT_ID_",
+	"definitionName":    "d.NAME_",
+	"definitionVersion": "d.VERSION_",
+}
+
+var sortDirections = map[string]string{
+	"asc":  "ASC",
+	"desc": "DESC",
+}
+
+const selectHistoricProcessInstances = `SELECT p.ID_, COALESCE(p.SUPER_PROCESS_INSTANCE_ID_, ''), COALESCE(p.SUPER_CASE_INSTANCE_ID_, ''), COALESCE(p.CASE_INST_ID_, ''),
+	COALESCE(d.NAME_, ''), COALESCE(p.PROC_DEF_KEY_, ''), COALESCE(d.VERSION_, 0), p.PROC_DEF_ID_, COALESCE(p.BUSINESS_KEY_, ''),
+	p.START_TIME_, p.END_TIME_, COALESCE(p.DURATION_, 0), COALESCE(p.START_USER_ID_, ''), COALESCE(p.START_ACT_ID_, ''),
+	COALESCE(p.DELETE_REASON_, ''), COALESCE(p.TENANT_ID_, ''), COALESCE(p.STATE_, '')
+	FROM ACT_HI_PROCINST p LEFT JOIN ACT_RE_PROCDEF d ON d.ID_ = p.PROC_DEF_ID_`
+
+func (this *Postgres) ListHistory(ctx context.Context, limit string, offset string, sortby string, sortdirection string, finished bool, filter camunda.HistoryFilter) (result camunda.HistoricProcessInstances, err error) {
+	return this.listHistory(ctx, limit, offset, sortby, sortdirection, finished, nil, filter)
+}
+
+func (this *Postgres) ListHistoryFinishedBefore(ctx context.Context, limit string, offset string, sortby string, sortdirection string, finished bool, before time.Time, filter camunda.HistoryFilter) (result camunda.HistoricProcessInstances, err error) {
+	return this.listHistory(ctx, limit, offset, sortby, sortdirection, finished, &before, filter)
+}
+
+func (this *Postgres) listHistory(ctx context.Context, limit string, offset string, sortby string, sortdirection string, finished bool, before *time.Time, filter camunda.HistoryFilter) (result camunda.HistoricProcessInstances, err error) {
+	sortColumn, ok := sortColumns[sortby]
+	if !ok {
+		return result, fmt.Errorf("unsupported sort by %v", sortby)
+	}
+	direction, ok := sortDirections[sortdirection]
+	if !ok {
+		return result, fmt.Errorf("unsupported sort order %v", sortdirection)
+	}
+	limitInt, err := strconv.Atoi(limit)
+	if err != nil {
+		return result, err
+	}
+	offsetInt, err := strconv.Atoi(offset)
+	if err != nil {
+		return result, err
+	}
+	conditions, args := this.historyConditions(finished, before, filter)
+	args = append(args, limitInt, offsetInt)
+	query := selectHistoricProcessInstances + " WHERE " + strings.Join(conditions, " AND ") +
+		" ORDER BY " + sortColumn + " " + direction + ", p.ID_ ASC" +
+		" LIMIT $" + strconv.Itoa(len(args)-1) + " OFFSET $" + strconv.Itoa(len(args))
+	rows, err := this.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return result, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		instance, err := this.scanHistoricProcessInstance(rows)
+		if err != nil {
+			return result, err
+		}
+		result = append(result, instance)
+	}
+	err = rows.Err()
+	if err != nil {
+		return result, err
+	}
+	if this.config.Debug {
+		log.Printf("DEBUG: read %v elements from ACT_HI_PROCINST", len(result))
+	}
+	return result, nil
+}
+
+// historyConditions mirrors the semantics of the camunda rest history query parameters
+func (this *Postgres) historyConditions(finished bool, before *time.Time, filter camunda.HistoryFilter) (conditions []string, args []interface{}) {
+	if finished {
+		conditions = append(conditions, "p.END_TIME_ IS NOT NULL")
+	} else {
+		conditions = append(conditions, "p.END_TIME_ IS NULL")
+	}
+	if before != nil {
+		args = append(args, this.formatTime(*before))
+		conditions = append(conditions, "p.END_TIME_ <= $"+strconv.Itoa(len(args))+"::timestamp")
+	}
+	if filter.ProcessDefinitionKey != "" {
+		args = append(args, filter.ProcessDefinitionKey)
+		conditions = append(conditions, "p.PROC_DEF_KEY_ = $"+strconv.Itoa(len(args)))
+	}
+	if len(filter.ProcessDefinitionKeyNotIn) > 0 {
+		args = append(args, pq.Array(filter.ProcessDefinitionKeyNotIn))
+		conditions = append(conditions, "p.PROC_DEF_KEY_ <> ALL($"+strconv.Itoa(len(args))+")")
+	}
+	if len(filter.TenantIdIn) > 0 {
+		args = append(args, pq.Array(filter.TenantIdIn))
+		conditions = append(conditions, "p.TENANT_ID_ = ANY($"+strconv.Itoa(len(args))+")")
+	}
+	if filter.WithoutTenantId {
+		conditions = append(conditions, "p.TENANT_ID_ IS NULL")
+	}
+	return conditions, args
+}
+
+func (this *Postgres) scanHistoricProcessInstance(rows *sql.Rows) (result camunda.HistoricProcessInstance, err error) {
+	var startTime, endTime sql.NullTime
+	err = rows.Scan(&result.Id, &result.SuperProcessInstanceId, &result.SuperCaseInstanceId, &result.CaseInstanceId,
+		&result.ProcessDefinitionName, &result.ProcessDefinitionKey, &result.ProcessDefinitionVersion, &result.ProcessDefinitionId, &result.BusinessKey,
+		&startTime, &endTime, &result.DurationInMillis, &result.StartUserId, &result.StartActivityId,
+		&result.DeleteReason, &result.TenantId, &result.State)
+	if err != nil {
+		return result, err
+	}
+	result.StartTime = this.formatNullTime(startTime)
+	result.EndTime = this.formatNullTime(endTime)
+	return result, nil
+}
+
+func (this *Postgres) ListHistoryCount(ctx context.Context, finished bool) (result camunda.Count, err error) {
+	conditions, args := this.historyConditions(finished, nil, camunda.HistoryFilter{})
+	err = this.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM ACT_HI_PROCINST p WHERE "+strings.Join(conditions, " AND "), args...).Scan(&result.Count)
+	return result, err
+}
+
+func (this *Postgres) ListHistoryCountFinishedBefore(ctx context.Context, before time.Time, filter camunda.HistoryFilter) (result camunda.Count, err error) {
+	conditions, args := this.historyConditions(true, &before, filter)
+	err = this.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM ACT_HI_PROCINST p WHERE "+strings.Join(conditions, " AND "), args...).Scan(&result.Count)
+	return result, err
+}
+
+// ListTenantIds returns the distinct tenant ids of all finished historic process instances
+func (this *Postgres) ListTenantIds(ctx context.Context) (result []string, err error) {
+	rows, err := this.db.QueryContext(ctx, "SELECT DISTINCT TENANT_ID_ FROM ACT_HI_PROCINST WHERE TENANT_ID_ IS NOT NULL AND END_TIME_ IS NOT NULL")
+	if err != nil {
+		return result, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var tenant string
+		err = rows.Scan(&tenant)
+		if err != nil {
+			return result, err
+		}
+		result = append(result, tenant)
+	}
+	return result, rows.Err()
+}
@@ -0,0 +1,109 @@
+/*
+ * Copyright 2020 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/SENERGY-Platform/process-history-cleanup/pkg/camunda"
+	"github.com/lib/pq"
+	"log"
+	"time"
+)
+
+type historyLogTable struct {
+	table      string
+	timeColumn string
+	statements []string //executed in order to remove the selected rows; $1 is the list of ids
+}
+
+var historyLogTables = map[string]historyLogTable{
+	camunda.HistoryTypeBatch: {table: "ACT_HI_BATCH", timeColumn: "END_TIME_", statements: []string{
+		//job logs of the seed, monitor and batch jobs are removed with their batch, like the engine does
+		`DELETE FROM ACT_GE_BYTEARRAY WHERE ID_ IN (SELECT l.JOB_EXCEPTION_STACK_ID_ FROM ACT_HI_JOB_LOG l JOIN ACT_HI_BATCH b ON l.JOB_DEF_ID_ IN (b.SEED_JOB_DEF_ID_, b.MONITOR_JOB_DEF_ID_, b.BATCH_JOB_DEF_ID_) WHERE b.ID_ = ANY($1) AND l.JOB_EXCEPTION_STACK_ID_ IS NOT NULL)`,
+		`DELETE FROM ACT_HI_JOB_LOG WHERE JOB_DEF_ID_ IN (SELECT UNNEST(ARRAY[SEED_JOB_DEF_ID_, MONITOR_JOB_DEF_ID_, BATCH_JOB_DEF_ID_]) FROM ACT_HI_BATCH WHERE ID_ = ANY($1))`,
+		`DELETE FROM ACT_HI_BATCH WHERE ID_ = ANY($1)`,
+	}},
+	camunda.HistoryTypeJobLog: {table: "ACT_HI_JOB_LOG", timeColumn: "TIMESTAMP_", statements: []string{
+		`DELETE FROM ACT_GE_BYTEARRAY WHERE ID_ IN (SELECT JOB_EXCEPTION_STACK_ID_ FROM ACT_HI_JOB_LOG WHERE ID_ = ANY($1) AND JOB_EXCEPTION_STACK_ID_ IS NOT NULL)`,
+		`DELETE FROM ACT_HI_JOB_LOG WHERE ID_ = ANY($1)`,
+	}},
+	camunda.HistoryTypeUserOperation: {table: "ACT_HI_OP_LOG", timeColumn: "TIMESTAMP_", statements: []string{
+		`DELETE FROM ACT_HI_OP_LOG WHERE ID_ = ANY($1)`,
+	}},
+}
+
+// HistoryLogTypes returns the history types that CleanupHistoryLog can handle
+func (this *Postgres) HistoryLogTypes() []string {
+	return []string{camunda.HistoryTypeBatch, camunda.HistoryTypeJobLog, camunda.HistoryTypeUserOperation}
+}
+
+// CleanupHistoryLog removes rows of the history type that are older than before
+// rows are removed in transactions of up to batchSize rows; the rows are only counted if remove is false
+func (this *Postgres) CleanupHistoryLog(ctx context.Context, historyType string, before time.Time, batchSize int, remove bool) (count int64, err error) {
+	logTable, ok := historyLogTables[historyType]
+	if !ok {
+		return 0, fmt.Errorf("%w: %v", camunda.ErrUnsupportedHistoryType, historyType)
+	}
+	condition := logTable.timeColumn + " <= $1::timestamp"
+	if !remove {
+		err = this.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM "+logTable.table+" WHERE "+condition, this.formatTime(before)).Scan(&count)
+		return count, err
+	}
+	for {
+		ids := []string{}
+		err = this.db.QueryRowContext(ctx, "SELECT ARRAY(SELECT ID_ FROM "+logTable.table+" WHERE "+condition+" ORDER BY "+logTable.timeColumn+" ASC LIMIT $2)", this.formatTime(before), batchSize).Scan(pq.Array(&ids))
+		if err != nil {
+			return count, err
+		}
+		if len(ids) == 0 {
+			return count, nil
+		}
+		err = this.removeHistoryLog(ctx, logTable, ids)
+		if err != nil {
+			return count, err
+		}
+		count = count + int64(len(ids))
+		if len(ids) < batchSize {
+			return count, nil
+		}
+	}
+}
+
+func (this *Postgres) removeHistoryLog(ctx context.Context, logTable historyLogTable, ids []string) (err error) {
+	tx, err := this.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			rollbackErr := tx.Rollback()
+			if rollbackErr != nil && !errors.Is(rollbackErr, sql.ErrTxDone) {
+				log.Println("ERROR: unable to rollback", rollbackErr)
+			}
+		}
+	}()
+	for _, statement := range logTable.statements {
+		_, err = tx.ExecContext(ctx, statement, pq.Array(ids))
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,114 @@
+/*
+ * Copyright 2020 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/SENERGY-Platform/process-history-cleanup/pkg/camunda"
+	"github.com/lib/pq"
+	"log"
+)
+
+type orphanTable struct {
+	table           string
+	byteArrayColumn string   //empty if the table does not reference ACT_GE_BYTEARRAY
+	dependents      []string //statements removing rows that reference the orphans; $1 is the list of orphan ids
+}
+
+var orphanTables = map[string]orphanTable{
+	camunda.HistoryTypeActivityInstance: {table: "ACT_HI_ACTINST"},
+	camunda.HistoryTypeVariableInstance: {table: "ACT_HI_VARINST", byteArrayColumn: "BYTEARRAY_ID_"},
+	camunda.HistoryTypeDetail:           {table: "ACT_HI_DETAIL", byteArrayColumn: "BYTEARRAY_ID_"},
+	camunda.HistoryTypeTaskInstance: {table: "ACT_HI_TASKINST", dependents: []string{
+		`DELETE FROM ACT_HI_IDENTITYLINK WHERE TASK_ID_ = ANY($1)`,
+	}},
+	camunda.HistoryTypeIncident: {table: "ACT_HI_INCIDENT"},
+}
+
+// OrphanHistoryTypes returns the history types that CleanupOrphans can handle
+func (this *Postgres) OrphanHistoryTypes() []string {
+	return []string{
+		camunda.HistoryTypeActivityInstance,
+		camunda.HistoryTypeVariableInstance,
+		camunda.HistoryTypeDetail,
+		camunda.HistoryTypeTaskInstance,
+		camunda.HistoryTypeIncident,
+	}
+}
+
+// CleanupOrphans removes rows of the history type that reference a process instance without ACT_HI_PROCINST row
+// rows are removed in transactions of up to batchSize orphans; the orphans are only counted if remove is false
+func (this *Postgres) CleanupOrphans(ctx context.Context, historyType string, batchSize int, remove bool) (count int64, err error) {
+	orphans, ok := orphanTables[historyType]
+	if !ok {
+		return 0, fmt.Errorf("%w: %v", camunda.ErrUnsupportedHistoryType, historyType)
+	}
+	condition := `t.PROC_INST_ID_ IS NOT NULL AND NOT EXISTS (SELECT 1 FROM ACT_HI_PROCINST p WHERE p.ID_ = t.PROC_INST_ID_)`
+	if !remove {
+		err = this.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM "+orphans.table+" t WHERE "+condition).Scan(&count)
+		return count, err
+	}
+	for {
+		ids := []string{}
+		err = this.db.QueryRowContext(ctx, "SELECT ARRAY(SELECT t.ID_ FROM "+orphans.table+" t WHERE "+condition+" LIMIT $1)", batchSize).Scan(pq.Array(&ids))
+		if err != nil {
+			return count, err
+		}
+		if len(ids) == 0 {
+			return count, nil
+		}
+		err = this.removeOrphans(ctx, orphans, ids)
+		if err != nil {
+			return count, err
+		}
+		count = count + int64(len(ids))
+		if len(ids) < batchSize {
+			return count, nil
+		}
+	}
+}
+
+func (this *Postgres) removeOrphans(ctx context.Context, orphans orphanTable, ids []string) (err error) {
+	tx, err := this.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			rollbackErr := tx.Rollback()
+			if rollbackErr != nil && !errors.Is(rollbackErr, sql.ErrTxDone) {
+				log.Println("ERROR: unable to rollback", rollbackErr)
+			}
+		}
+	}()
+	statements := []string{}
+	if orphans.byteArrayColumn != "" {
+		statements = append(statements, "DELETE FROM ACT_GE_BYTEARRAY WHERE ID_ IN (SELECT "+orphans.byteArrayColumn+" FROM "+orphans.table+" WHERE ID_ = ANY($1) AND "+orphans.byteArrayColumn+" IS NOT NULL)")
+	}
+	statements = append(statements, orphans.dependents...)
+	statements = append(statements, "DELETE FROM "+orphans.table+" WHERE ID_ = ANY($1)")
+	for _, statement := range statements {
+		_, err = tx.ExecContext(ctx, statement, pq.Array(ids))
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,71 @@
+/*
+ * Copyright 2020 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package postgres
+
+import (
+	"database/sql"
+	"github.com/SENERGY-Platform/process-history-cleanup/pkg/configuration"
+	_ "github.com/lib/pq"
+	"log"
+	"time"
+)
+
+// Postgres works directly on the history tables of the camunda database
+// it is an alternative to camunda.Camunda for installations where the rest api is too slow
+type Postgres struct {
+	config   configuration.Config
+	db       *sql.DB
+	timezone *time.Location
+}
+
+// timestamps without time zone are written in the time zone of the engine jvm
+const timestampFormat = "2006-01-02 15:04:05.999999"
+
+func New(config configuration.Config) (*Postgres, error) {
+	timezone := time.UTC
+	if config.PostgresTimeZone != "" {
+		var err error
+		timezone, err = time.LoadLocation(config.PostgresTimeZone)
+		if err != nil {
+			return nil, err
+		}
+	}
+	db, err := sql.Open("postgres", config.PostgresConnStr)
+	if err != nil {
+		return nil, err
+	}
+	err = db.Ping()
+	if err != nil {
+		log.Println("ERROR: unable to connect to camunda database", err)
+		db.Close()
+		return nil, err
+	}
+	return &Postgres{config: config, db: db, timezone: timezone}, nil
+}
+
+func (this *Postgres) Close() error {
+	return this.db.Close()
+}
+
+func (this *Postgres) formatTime(t time.Time) string {
+	return t.In(this.timezone).Format(timestampFormat)
+}
+
+// parseTime interprets the wall clock of a timestamp without time zone in this.timezone
+func (this *Postgres) parseTime(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), this.timezone)
+}
@@ -0,0 +1,123 @@
+/*
+ * Copyright 2020 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pkg
+
+import (
+	"github.com/SENERGY-Platform/process-history-cleanup/pkg/camunda"
+	"log"
+	"sort"
+	"strings"
+)
+
+type DryRunReport struct {
+	listIds   bool
+	groups    map[DryRunGroup]*DryRunGroupResult
+	decisions map[DryRunDecisionGroup]*DryRunGroupResult
+}
+
+type DryRunGroup struct {
+	ProcessDefinitionKey string
+	TenantId             string
+}
+
+type DryRunDecisionGroup struct {
+	DecisionDefinitionKey string
+	TenantId              string
+}
+
+type DryRunGroupResult struct {
+	Count int
+	Ids   []string
+}
+
+func NewDryRunReport(listIds bool) *DryRunReport {
+	return &DryRunReport{listIds: listIds, groups: map[DryRunGroup]*DryRunGroupResult{}, decisions: map[DryRunDecisionGroup]*DryRunGroupResult{}}
+}
+
+func (this *DryRunReport) Add(instance camunda.HistoricProcessInstance) {
+	group := DryRunGroup{ProcessDefinitionKey: instance.ProcessDefinitionKey, TenantId: instance.TenantId}
+	result, ok := this.groups[group]
+	if !ok {
+		result = &DryRunGroupResult{}
+		this.groups[group] = result
+	}
+	result.Count++
+	if this.listIds {
+		result.Ids = append(result.Ids, instance.Id)
+	}
+}
+
+func (this *DryRunReport) AddDecision(instance camunda.HistoricDecisionInstance) {
+	group := DryRunDecisionGroup{DecisionDefinitionKey: instance.DecisionDefinitionKey, TenantId: instance.TenantId}
+	result, ok := this.decisions[group]
+	if !ok {
+		result = &DryRunGroupResult{}
+		this.decisions[group] = result
+	}
+	result.Count++
+	if this.listIds {
+		result.Ids = append(result.Ids, instance.Id)
+	}
+}
+
+func (this *DryRunReport) Total() (result int) {
+	for _, group := range this.groups {
+		result = result + group.Count
+	}
+	return result
+}
+
+func (this *DryRunReport) Log() {
+	groups := []DryRunGroup{}
+	for group := range this.groups {
+		groups = append(groups, group)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].ProcessDefinitionKey != groups[j].ProcessDefinitionKey {
+			return groups[i].ProcessDefinitionKey < groups[j].ProcessDefinitionKey
+		}
+		return groups[i].TenantId < groups[j].TenantId
+	})
+	for _, group := range groups {
+		result := this.groups[group]
+		log.Printf("DRY-RUN: would delete %v instances of process definition key=%q tenant=%q", result.Count, group.ProcessDefinitionKey, group.TenantId)
+		if this.listIds {
+			log.Printf("DRY-RUN: ids: %v", strings.Join(result.Ids, ","))
+		}
+	}
+	log.Printf("DRY-RUN: would delete %v instances in total", this.Total())
+	if len(this.decisions) == 0 {
+		return
+	}
+	decisions := []DryRunDecisionGroup{}
+	for group := range this.decisions {
+		decisions = append(decisions, group)
+	}
+	sort.Slice(decisions, func(i, j int) bool {
+		if decisions[i].DecisionDefinitionKey != decisions[j].DecisionDefinitionKey {
+			return decisions[i].DecisionDefinitionKey < decisions[j].DecisionDefinitionKey
+		}
+		return decisions[i].TenantId < decisions[j].TenantId
+	})
+	for _, group := range decisions {
+		result := this.decisions[group]
+		log.Printf("DRY-RUN: would delete %v instances of decision definition key=%q tenant=%q", result.Count, group.DecisionDefinitionKey, group.TenantId)
+		if this.listIds {
+			log.Printf("DRY-RUN: ids: %v", strings.Join(result.Ids, ","))
+		}
+	}
+}
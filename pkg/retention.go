@@ -0,0 +1,247 @@
+/*
+ * Copyright 2020 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/SENERGY-Platform/process-history-cleanup/pkg/camunda"
+	"github.com/SENERGY-Platform/process-history-cleanup/pkg/configuration"
+)
+
+// camunda accepts tenantIdIn as comma separated query parameter
+// chunking keeps the request urls short if many tenants are known
+const tenantFilterChunkSize = 50
+
+// retentionTarget describes one history query with its own max age
+// every target results in separate ListHistoryFinishedBefore calls
+type retentionTarget struct {
+	description string
+	filter      camunda.HistoryFilter
+	maxAge      configuration.Duration
+}
+
+// decisionRetentionTarget describes one decision history query with its own max age
+type decisionRetentionTarget struct {
+	description string
+	filter      camunda.DecisionFilter
+	maxAge      configuration.Duration
+}
+
+// getRetentionTargets translates the configured rules into disjoint history queries
+// precedence: exempt tenants > tenant rules > process definition key rules > default max age
+// the process definition key targets are queried per tenant with tenantIdIn chunks and once with withoutTenantId,
+// so that the history of exempt and ruled tenants is never listed by them; tenants are found by their history
+func getRetentionTargets(ctx context.Context, config configuration.Config, engine Camunda) (result []retentionTarget, err error) {
+	keyTargets, err := getProcessDefinitionKeyTargets(config)
+	if err != nil {
+		return result, err
+	}
+	if len(config.TenantRetentionRules) == 0 && len(config.ExemptTenants) == 0 {
+		return keyTargets, nil
+	}
+
+	excluded := map[string]bool{}
+	for _, tenant := range config.ExemptTenants {
+		if tenant == "" {
+			return result, errors.New("expect non empty tenant id in exempt_tenants")
+		}
+		excluded[tenant] = true
+	}
+	tenantTargets := []retentionTarget{}
+	for _, rule := range config.TenantRetentionRules {
+		if rule.TenantId == "" {
+			return result, errors.New("expect tenant_id in tenant retention rule")
+		}
+		if excluded[rule.TenantId] {
+			return result, fmt.Errorf("tenant %v is exempt or has more than one retention rule", rule.TenantId)
+		}
+		excluded[rule.TenantId] = true
+		maxAge, err := configuration.ParseDuration(rule.MaxAge)
+		if err != nil {
+			return result, fmt.Errorf("invalid max_age in tenant retention rule for %v: %w", rule.TenantId, err)
+		}
+		tenantTargets = append(tenantTargets, retentionTarget{
+			description: "tenant " + rule.TenantId,
+			filter:      camunda.HistoryFilter{TenantIdIn: []string{rule.TenantId}},
+			maxAge:      maxAge,
+		})
+	}
+
+	tenants, err := engine.ListTenantIds(ctx)
+	if err != nil {
+		return result, err
+	}
+	remaining := []string{}
+	for _, tenant := range tenants {
+		if !excluded[tenant] {
+			remaining = append(remaining, tenant)
+		}
+	}
+
+	for _, target := range keyTargets {
+		withoutTenant := target
+		withoutTenant.description = target.description + " without tenant"
+		withoutTenant.filter.WithoutTenantId = true
+		result = append(result, withoutTenant)
+		for start := 0; start < len(remaining); start = start + tenantFilterChunkSize {
+			end := min(start+tenantFilterChunkSize, len(remaining))
+			withTenants := target
+			withTenants.description = fmt.Sprintf("%v for %v tenants", target.description, end-start)
+			withTenants.filter.TenantIdIn = remaining[start:end]
+			result = append(result, withTenants)
+		}
+	}
+	return append(result, tenantTargets...), nil
+}
+
+func getProcessDefinitionKeyTargets(config configuration.Config) (result []retentionTarget, err error) {
+	defaultMaxAge, err := configuration.ParseDuration(config.MaxAge)
+	if err != nil {
+		return result, err
+	}
+	ruleKeys := []string{}
+	known := map[string]bool{}
+	for _, rule := range config.RetentionRules {
+		if rule.ProcessDefinitionKey == "" {
+			return result, errors.New("expect process_definition_key in retention rule")
+		}
+		if known[rule.ProcessDefinitionKey] {
+			return result, fmt.Errorf("duplicate retention rule for process definition key %v", rule.ProcessDefinitionKey)
+		}
+		known[rule.ProcessDefinitionKey] = true
+		maxAge, err := configuration.ParseDuration(rule.MaxAge)
+		if err != nil {
+			return result, fmt.Errorf("invalid max_age in retention rule for %v: %w", rule.ProcessDefinitionKey, err)
+		}
+		ruleKeys = append(ruleKeys, rule.ProcessDefinitionKey)
+		result = append(result, retentionTarget{
+			description: "process definition key " + rule.ProcessDefinitionKey,
+			filter:      camunda.HistoryFilter{ProcessDefinitionKey: rule.ProcessDefinitionKey},
+			maxAge:      maxAge,
+		})
+	}
+	result = append(result, retentionTarget{
+		description: "default",
+		filter:      camunda.HistoryFilter{ProcessDefinitionKeyNotIn: ruleKeys},
+		maxAge:      defaultMaxAge,
+	})
+	return result, nil
+}
+
+// getDecisionRetentionTargets applies the tenant precedence of getRetentionTargets to the decision definition key targets
+// tenant rules only apply to decisions that are cleaned up at all: to every decision if config.DecisionMaxAge is set,
+// otherwise to the decision definition keys of the decision retention rules
+func getDecisionRetentionTargets(ctx context.Context, config configuration.Config, engine Camunda) (result []decisionRetentionTarget, err error) {
+	keyTargets, err := getDecisionDefinitionKeyTargets(config)
+	if err != nil {
+		return result, err
+	}
+	if len(keyTargets) == 0 || (len(config.TenantRetentionRules) == 0 && len(config.ExemptTenants) == 0) {
+		return keyTargets, nil
+	}
+	//tenant ids are validated by getRetentionTargets
+	excluded := map[string]bool{}
+	for _, tenant := range config.ExemptTenants {
+		excluded[tenant] = true
+	}
+	tenantTargets := []decisionRetentionTarget{}
+	for _, rule := range config.TenantRetentionRules {
+		excluded[rule.TenantId] = true
+		maxAge, err := configuration.ParseDuration(rule.MaxAge)
+		if err != nil {
+			return result, fmt.Errorf("invalid max_age in tenant retention rule for %v: %w", rule.TenantId, err)
+		}
+		target := decisionRetentionTarget{
+			description: "decisions of tenant " + rule.TenantId,
+			filter:      camunda.DecisionFilter{TenantIdIn: []string{rule.TenantId}},
+			maxAge:      maxAge,
+		}
+		if config.DecisionMaxAge == "" || config.DecisionMaxAge == "-" {
+			for _, decisionRule := range config.DecisionRetentionRules {
+				target.filter.DecisionDefinitionKeyIn = append(target.filter.DecisionDefinitionKeyIn, decisionRule.DecisionDefinitionKey)
+			}
+		}
+		tenantTargets = append(tenantTargets, target)
+	}
+
+	tenants, err := engine.ListDecisionTenantIds(ctx)
+	if err != nil {
+		return result, err
+	}
+	remaining := []string{}
+	for _, tenant := range tenants {
+		if !excluded[tenant] {
+			remaining = append(remaining, tenant)
+		}
+	}
+
+	for _, target := range keyTargets {
+		withoutTenant := target
+		withoutTenant.description = target.description + " without tenant"
+		withoutTenant.filter.WithoutTenantId = true
+		result = append(result, withoutTenant)
+		for start := 0; start < len(remaining); start = start + tenantFilterChunkSize {
+			end := min(start+tenantFilterChunkSize, len(remaining))
+			withTenants := target
+			withTenants.description = fmt.Sprintf("%v for %v tenants", target.description, end-start)
+			withTenants.filter.TenantIdIn = remaining[start:end]
+			result = append(result, withTenants)
+		}
+	}
+	return append(result, tenantTargets...), nil
+}
+
+// getDecisionDefinitionKeyTargets returns one target per decision retention rule
+// and, if config.DecisionMaxAge is set, one target for all other decision definition keys
+// decisions are found by their history, so that history of removed decision definitions is cleaned too
+func getDecisionDefinitionKeyTargets(config configuration.Config) (result []decisionRetentionTarget, err error) {
+	ruleKeys := []string{}
+	known := map[string]bool{}
+	for _, rule := range config.DecisionRetentionRules {
+		if rule.DecisionDefinitionKey == "" {
+			return result, errors.New("expect decision_definition_key in decision retention rule")
+		}
+		if known[rule.DecisionDefinitionKey] {
+			return result, fmt.Errorf("duplicate decision retention rule for decision definition key %v", rule.DecisionDefinitionKey)
+		}
+		known[rule.DecisionDefinitionKey] = true
+		maxAge, err := configuration.ParseDuration(rule.MaxAge)
+		if err != nil {
+			return result, fmt.Errorf("invalid max_age in decision retention rule for %v: %w", rule.DecisionDefinitionKey, err)
+		}
+		ruleKeys = append(ruleKeys, rule.DecisionDefinitionKey)
+		result = append(result, decisionRetentionTarget{
+			description: "decision definition key " + rule.DecisionDefinitionKey,
+			filter:      camunda.DecisionFilter{DecisionDefinitionKeyIn: []string{rule.DecisionDefinitionKey}},
+			maxAge:      maxAge,
+		})
+	}
+	if config.DecisionMaxAge == "" || config.DecisionMaxAge == "-" {
+		return result, nil
+	}
+	defaultMaxAge, err := configuration.ParseDuration(config.DecisionMaxAge)
+	if err != nil {
+		return result, fmt.Errorf("invalid decision_max_age: %w", err)
+	}
+	return append(result, decisionRetentionTarget{
+		description: "default decisions",
+		filter:      camunda.DecisionFilter{DecisionDefinitionKeyNotIn: ruleKeys},
+		maxAge:      defaultMaxAge,
+	}), nil
+}
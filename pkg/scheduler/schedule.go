@@ -0,0 +1,96 @@
+/*
+ * Copyright 2020 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package scheduler
+
+import (
+	"errors"
+	"github.com/SENERGY-Platform/process-history-cleanup/pkg/configuration"
+	"github.com/robfig/cron/v3"
+	"time"
+)
+
+type Schedule interface {
+	// Next returns the first run time after t
+	Next(t time.Time) time.Time
+}
+
+// FromConfig returns nil if neither config.Interval nor config.Schedule is set
+func FromConfig(config configuration.Config) (Schedule, error) {
+	hasInterval := config.Interval != "" && config.Interval != "-"
+	hasSchedule := config.Schedule != "" && config.Schedule != "-"
+	if hasInterval && hasSchedule {
+		return nil, errors.New("expect either interval or schedule, not both")
+	}
+	if !hasInterval && !hasSchedule {
+		return nil, nil
+	}
+	location, err := time.LoadLocation(config.Location)
+	if err != nil {
+		return nil, err
+	}
+	if hasInterval {
+		interval, err := configuration.ParseDuration(config.Interval)
+		if err != nil {
+			return nil, err
+		}
+		if interval.IsZero() {
+			return nil, errors.New("expect interval > 0")
+		}
+		return &IntervalSchedule{interval: interval, location: location}, nil
+	}
+	spec, err := cron.ParseStandard(config.Schedule)
+	if err != nil {
+		return nil, err
+	}
+	return &CronSchedule{spec: spec, location: location}, nil
+}
+
+// IntervalSchedule runs every interval; calendar units like "1d" are evaluated in the configured location
+type IntervalSchedule struct {
+	interval configuration.Duration
+	location *time.Location
+}
+
+func (this *IntervalSchedule) Next(t time.Time) time.Time {
+	return this.interval.After(t, this.location)
+}
+
+// CronSchedule evaluates a standard 5 field cron expression in the configured location
+type CronSchedule struct {
+	spec     cron.Schedule
+	location *time.Location
+}
+
+func (this *CronSchedule) Next(t time.Time) time.Time {
+	return this.spec.Next(t.In(this.location))
+}
+
+// Period returns the time between the next two runs after now
+func Period(schedule Schedule, now time.Time) time.Duration {
+	next := schedule.Next(now)
+	return schedule.Next(next).Sub(next)
+}
+
+// NextAfter returns the first run time of schedule after last that is not in the past
+// runs missed while a cleanup was still running are skipped
+func NextAfter(schedule Schedule, last time.Time, now time.Time) time.Time {
+	next := schedule.Next(last)
+	for next.Before(now) {
+		next = schedule.Next(next)
+	}
+	return next
+}
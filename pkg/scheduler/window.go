@@ -0,0 +1,123 @@
+/*
+ * Copyright 2020 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package scheduler
+
+import (
+	"fmt"
+	"github.com/SENERGY-Platform/process-history-cleanup/pkg/configuration"
+	"time"
+)
+
+// Windows limits cleanups to the configured maintenance windows
+// a nil *Windows or one without windows is always open
+type Windows struct {
+	windows  []window
+	location *time.Location
+}
+
+type window struct {
+	weekdays map[time.Weekday]bool //empty for every day; a window that spans midnight belongs to the day it starts
+	start    time.Duration         //since midnight
+	end      time.Duration         //since midnight; start >= end spans midnight
+}
+
+func WindowsFromConfig(config configuration.Config) (result *Windows, err error) {
+	location, err := time.LoadLocation(config.Location)
+	if err != nil {
+		return nil, err
+	}
+	result = &Windows{location: location}
+	for _, w := range config.MaintenanceWindows {
+		parsed := window{weekdays: map[time.Weekday]bool{}}
+		for _, day := range w.Weekdays {
+			weekday, err := configuration.ParseWeekday(day)
+			if err != nil {
+				return nil, fmt.Errorf("invalid maintenance window: %w", err)
+			}
+			parsed.weekdays[weekday] = true
+		}
+		parsed.start, err = configuration.ParseTimeOfDay(w.Start)
+		if err != nil {
+			return nil, fmt.Errorf("invalid start of maintenance window: %w", err)
+		}
+		parsed.end, err = configuration.ParseTimeOfDay(w.End)
+		if err != nil {
+			return nil, fmt.Errorf("invalid end of maintenance window: %w", err)
+		}
+		result.windows = append(result.windows, parsed)
+	}
+	return result, nil
+}
+
+// Open reports whether t is inside of a maintenance window
+func (this *Windows) Open(t time.Time) bool {
+	if this == nil || len(this.windows) == 0 {
+		return true
+	}
+	t = t.In(this.location)
+	today := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, this.location)
+	for _, w := range this.windows {
+		for _, day := range []time.Time{today.AddDate(0, 0, -1), today} {
+			start, end, ok := w.on(day)
+			if ok && !t.Before(start) && t.Before(end) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// NextOpen returns t if a window is open at t, otherwise the start of the next window
+func (this *Windows) NextOpen(t time.Time) time.Time {
+	if this.Open(t) {
+		return t
+	}
+	t = t.In(this.location)
+	today := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, this.location)
+	var result time.Time
+	for offset := 0; offset <= 7; offset++ {
+		day := today.AddDate(0, 0, offset)
+		for _, w := range this.windows {
+			start, _, ok := w.on(day)
+			if ok && start.After(t) && (result.IsZero() || start.Before(result)) {
+				result = start
+			}
+		}
+		if !result.IsZero() {
+			return result
+		}
+	}
+	return result
+}
+
+// on returns the window interval that starts on the given day
+func (this window) on(day time.Time) (start time.Time, end time.Time, ok bool) {
+	if len(this.weekdays) > 0 && !this.weekdays[day.Weekday()] {
+		return start, end, false
+	}
+	start = atTimeOfDay(day, this.start)
+	if this.end > this.start {
+		end = atTimeOfDay(day, this.end)
+	} else {
+		end = atTimeOfDay(day.AddDate(0, 0, 1), this.end)
+	}
+	return start, end, true
+}
+
+func atTimeOfDay(day time.Time, offset time.Duration) time.Time {
+	return time.Date(day.Year(), day.Month(), day.Day(), int(offset/time.Hour), int(offset%time.Hour/time.Minute), 0, 0, day.Location())
+}
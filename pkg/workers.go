@@ -0,0 +1,125 @@
+/*
+ * Copyright 2020 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/SENERGY-Platform/process-history-cleanup/pkg/camunda"
+	"log"
+	"sync"
+)
+
+var ErrDeleteFailed = errors.New("unable to delete process instance history")
+
+// errEveryDeletionFailed stops a run; the causes are already collected in deleteFailures
+var errEveryDeletionFailed = errors.New("every deletion of the batch failed")
+
+// only the first errors are kept to keep the resulting error message readable
+const maxReportedDeleteErrors = 10
+
+type deleteFailures struct {
+	mux    sync.Mutex
+	count  int
+	errors []error
+}
+
+func (this *deleteFailures) add(id string, err error) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	this.count++
+	if len(this.errors) < maxReportedDeleteErrors {
+		this.errors = append(this.errors, fmt.Errorf("%v: %w", id, err))
+	}
+}
+
+func (this *deleteFailures) total() int {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	return this.count
+}
+
+// withFailures adds the collected deletion failures to err, which stopped the run
+func (this *deleteFailures) withFailures(err error) error {
+	if errors.Is(err, errEveryDeletionFailed) {
+		return this.err()
+	}
+	return errors.Join(err, this.err())
+}
+
+// err returns nil if no deletion failed
+func (this *deleteFailures) err() error {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	if this.count == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w: %v failed deletions: %w", ErrDeleteFailed, this.count, errors.Join(this.errors...))
+}
+
+// removeEach deletes the instances with up to this.concurrency parallel requests
+// failed deletions are collected in this.failures; the failed instances remain in the engine and are returned as kept,
+// so that the caller can page over them while the oldest instances are still processed first
+// if every deletion of the batch fails, errEveryDeletionFailed is returned to stop the run
+// if ctx is done, no further deletions are started; in-flight deletions are completed and ctx.Err() is returned
+func (this *cleaner) removeEach(ctx context.Context, instances camunda.HistoricProcessInstances) (kept int, err error) {
+	inFlightCtx := context.WithoutCancel(ctx)
+	jobs := make(chan camunda.HistoricProcessInstance)
+	mux := sync.Mutex{}
+	wg := sync.WaitGroup{}
+	for i := 0; i < min(max(this.concurrency, 1), len(instances)); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for instance := range jobs {
+				log.Println("delete " + instance.Id)
+				err := this.engine.RemoveProcessInstanceHistory(inFlightCtx, instance.Id)
+				if err != nil {
+					log.Println("ERROR: unable to delete", instance.Id, err)
+					this.metrics.DeleteFailed(instance.ProcessDefinitionKey, instance.TenantId)
+					this.failures.add(instance.Id, err)
+					mux.Lock()
+					kept++
+					mux.Unlock()
+					continue
+				}
+				this.deleted.Add(1)
+				this.metrics.Deleted(instance.ProcessDefinitionKey, instance.TenantId)
+			}
+		}()
+	}
+	dispatched := 0
+dispatch:
+	for _, instance := range instances {
+		select {
+		case <-ctx.Done():
+			break dispatch
+		case jobs <- instance:
+			dispatched++
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	if dispatched < len(instances) {
+		return kept + len(instances) - dispatched, ctx.Err()
+	}
+	if len(instances) > 0 && kept == len(instances) {
+		return kept, errEveryDeletionFailed
+	}
+	return kept, nil
+}
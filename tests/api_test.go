@@ -0,0 +1,94 @@
+/*
+ * Copyright 2020 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tests
+
+import (
+	"github.com/SENERGY-Platform/process-history-cleanup/pkg/api"
+	"github.com/SENERGY-Platform/process-history-cleanup/pkg/configuration"
+	"github.com/SENERGY-Platform/process-history-cleanup/pkg/metrics"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func getStatus(t *testing.T, url string) int {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestHealth(t *testing.T) {
+	m := metrics.New()
+	router, update := api.NewRouter(&configuration.ConfigStruct{
+		Location:             "Europe/Berlin",
+		Interval:             "100ms",
+		EngineRequestTimeout: "100ms",
+		EngineRetryMaxDelay:  "100ms",
+	}, m)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	if status := getStatus(t, server.URL+"/health"); status != http.StatusOK {
+		t.Error("fresh heartbeat", status)
+	}
+
+	//a run without engine requests or deletions is hung, even if it is still in progress
+	m.RunStarted()
+	time.Sleep(500 * time.Millisecond)
+	if status := getStatus(t, server.URL+"/health"); status != http.StatusServiceUnavailable {
+		t.Error("hung run", status)
+	}
+
+	m.Deleted("key", "")
+	if status := getStatus(t, server.URL+"/health"); status != http.StatusOK {
+		t.Error("deletion heartbeat", status)
+	}
+
+	//without schedule the heartbeat is not checked
+	update(&configuration.ConfigStruct{Location: "Europe/Berlin"})
+	time.Sleep(500 * time.Millisecond)
+	if status := getStatus(t, server.URL+"/health"); status != http.StatusOK {
+		t.Error("without schedule", status)
+	}
+}
+
+func TestReady(t *testing.T) {
+	engine := newFakeCamunda()
+	engine.addFinished("finished", "key", "", time.Hour)
+	engineServer := httptest.NewServer(engine)
+	defer engineServer.Close()
+
+	router, update := api.NewRouter(&configuration.ConfigStruct{EngineUrl: engineServer.URL, Location: "Europe/Berlin"}, metrics.New())
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	if status := getStatus(t, server.URL+"/ready"); status != http.StatusOK {
+		t.Error("reachable engine", status)
+	}
+
+	unreachable := httptest.NewServer(engine)
+	unreachable.Close()
+	update(&configuration.ConfigStruct{EngineUrl: unreachable.URL, Location: "Europe/Berlin"})
+	if status := getStatus(t, server.URL+"/ready"); status != http.StatusServiceUnavailable {
+		t.Error("unreachable engine", status)
+	}
+}
@@ -0,0 +1,119 @@
+/*
+ * Copyright 2020 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tests
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"github.com/SENERGY-Platform/process-history-cleanup/pkg/archive"
+	"github.com/SENERGY-Platform/process-history-cleanup/pkg/camunda"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestFileArchive(t *testing.T) {
+	dir := t.TempDir()
+	sink, err := archive.NewFileSink(dir, 1000, true)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	for batch := 0; batch < 10; batch++ {
+		err = sink.Write(context.Background(), createArchiveRecords(batch, 5))
+		if err != nil {
+			t.Error(err)
+			return
+		}
+	}
+	err = sink.Close()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.ndjson.gz"))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(files) < 2 {
+		t.Error("expect rotated files", files)
+		return
+	}
+	ids := map[string]bool{}
+	for _, name := range files {
+		records, err := readArchiveFile(name)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		for _, record := range records {
+			if len(record.VariableInstances) != 1 || record.VariableInstances[0].ProcessInstanceId != record.Instance.Id {
+				t.Error(record)
+			}
+			ids[record.Instance.Id] = true
+		}
+	}
+	if len(ids) != 50 {
+		t.Error(len(ids))
+	}
+}
+
+func createArchiveRecords(batch int, count int) (result []archive.Record) {
+	for i := 0; i < count; i++ {
+		id := strconv.Itoa(batch) + "-" + strconv.Itoa(i)
+		result = append(result, archive.Record{
+			Instance:          camunda.HistoricProcessInstance{Id: id, ProcessDefinitionKey: "test", TenantId: "owner"},
+			ActivityInstances: []camunda.HistoricActivityInstance{{Id: "a" + id, ProcessInstanceId: id}},
+			VariableInstances: []camunda.HistoricVariableInstance{{Id: "v" + id, ProcessInstanceId: id, Name: "foo", Type: "String", Value: "bar"}},
+		})
+	}
+	return result
+}
+
+func readArchiveFile(name string) (result []archive.Record, err error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return result, err
+	}
+	defer file.Close()
+	var reader io.Reader = file
+	if filepath.Ext(name) == ".gz" {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return result, err
+		}
+		defer gz.Close()
+		reader = gz
+	}
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 1024*1024), 10*1024*1024)
+	for scanner.Scan() {
+		record := archive.Record{}
+		err = json.Unmarshal(scanner.Bytes(), &record)
+		if err != nil {
+			return result, err
+		}
+		result = append(result, record)
+	}
+	return result, scanner.Err()
+}
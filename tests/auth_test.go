@@ -0,0 +1,111 @@
+/*
+ * Copyright 2020 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/SENERGY-Platform/process-history-cleanup/pkg/camunda"
+	"github.com/SENERGY-Platform/process-history-cleanup/pkg/configuration"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+)
+
+func TestEngineAuth(t *testing.T) {
+	fake := newFakeCamunda()
+	expectedAuth := ""
+	tokenRequests := atomic.Int64{}
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if request.URL.Path == "/token" {
+			tokenRequests.Add(1)
+			id, secret, _ := request.BasicAuth()
+			if id != "cleanup" || secret != "client-secret" || request.FormValue("grant_type") != "client_credentials" {
+				http.Error(writer, `{"error":"invalid_client"}`, http.StatusUnauthorized)
+				return
+			}
+			writer.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(writer).Encode(map[string]interface{}{"access_token": "oauth-token", "token_type": "Bearer", "expires_in": 300})
+			return
+		}
+		if request.Header.Get("Authorization") != expectedAuth {
+			http.Error(writer, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		fake.ServeHTTP(writer, request)
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	writeSecret := func(name string, value string) string {
+		file := filepath.Join(dir, name)
+		err := os.WriteFile(file, []byte(value+"\n"), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return file
+	}
+
+	run := func(name string, config configuration.ConfigStruct, auth string, requests int) {
+		t.Run(name, func(t *testing.T) {
+			config.EngineUrl = server.URL
+			config.Location = "Europe/Berlin"
+			expectedAuth = auth
+			engine := camunda.New(&config)
+			for i := 0; i < requests; i++ {
+				_, err := engine.ListHistoryCount(context.Background(), true)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		})
+	}
+
+	run("basic", configuration.ConfigStruct{
+		EngineAuth:         camunda.AuthBasic,
+		EngineUser:         "user",
+		EnginePasswordFile: writeSecret("password", "pw"),
+	}, "Basic dXNlcjpwdw==", 1)
+
+	run("bearer", configuration.ConfigStruct{
+		EngineAuth:  camunda.AuthBearer,
+		EngineToken: "static-token",
+	}, "Bearer static-token", 1)
+
+	run("oauth2", configuration.ConfigStruct{
+		EngineAuth:             camunda.AuthOAuth2,
+		EngineTokenUrl:         server.URL + "/token",
+		EngineClientId:         "cleanup",
+		EngineClientSecretFile: writeSecret("client-secret", "client-secret"),
+	}, "Bearer oauth-token", 3)
+	if tokenRequests.Load() != 1 {
+		t.Error("expect token reuse", tokenRequests.Load())
+	}
+
+	t.Run("missing credentials", func(t *testing.T) {
+		expectedAuth = "Bearer static-token"
+		_, err := camunda.New(&configuration.ConfigStruct{EngineUrl: server.URL, Location: "Europe/Berlin"}).ListHistoryCount(context.Background(), true)
+		if !errors.Is(err, camunda.ErrUnexpectedResponse) {
+			t.Error(err)
+		}
+	})
+}
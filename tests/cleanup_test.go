@@ -28,6 +28,8 @@ import (
 	"log"
 	"net/http"
 	"net/url"
+	"os"
+	"regexp"
 	"strconv"
 	"strings"
 	"sync"
@@ -43,6 +45,10 @@ func TestCleanupLocalFilter(t *testing.T) {
 	t.Run("with batch", testCleanup(2, 3, true, false))
 }
 
+func TestCleanupBatchDelete(t *testing.T) {
+	t.Run("with batch", testCleanupWithStrategy(2, 3, true, false, pkg.DeleteStrategyBatch))
+}
+
 func TestCleanupLong(t *testing.T) {
 	t.Skip()
 	t.Run("with batch", testCleanup(500, 10000, false, true))
@@ -58,12 +64,191 @@ func TestCleanupExtraLong(t *testing.T) {
 	t.Run("with batch", testCleanup(500, 100000, false, true))
 }
 
+func TestCleanupExtraLongBatchDelete(t *testing.T) {
+	t.Skip()
+	t.Run("with batch", testCleanupWithStrategy(500, 100000, false, false, pkg.DeleteStrategyBatch))
+}
+
 func TestCleanupExtraLongLocalFilter(t *testing.T) {
 	t.Skip()
 	t.Run("with batch", testCleanup(500, 100000, false, true))
 }
 
+func TestCleanupRetentionRules(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	wg := &sync.WaitGroup{}
+	defer wg.Wait()
+	defer cancel()
+
+	_, camundaPgIp, _, err := docker.PostgresWithNetwork(ctx, wg, "camunda")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	camundaUrl, err := docker.Camunda(ctx, wg, camundaPgIp, "5432")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	shortLivedId, err := deployProcess(camundaUrl, "short", createBlankProcessWithKey("short_lived"), "<svg/>", "owner", "test")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	longLivedId, err := deployProcess(camundaUrl, "long", createBlankProcessWithKey("long_lived"), "<svg/>", "owner", "test")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	t.Run("start short lived processes", testStartProcesses(camundaUrl, shortLivedId, 5))
+	t.Run("start long lived processes", testStartProcesses(camundaUrl, longLivedId, 3))
+	t.Run("check created instances", testRunCheck(camundaUrl, 8))
+
+	time.Sleep(3 * time.Second)
+
+	t.Run("run cleanup", func(t *testing.T) {
+		err := pkg.RunCleanup(context.Background(), &configuration.ConfigStruct{
+			EngineUrl: camundaUrl,
+			MaxAge:    "10m",
+			RetentionRules: []configuration.RetentionRule{
+				{ProcessDefinitionKey: "short_lived", MaxAge: "2s"},
+			},
+			BatchSize: 2,
+			Location:  "Europe/Berlin",
+		}, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+	})
+	t.Run("check long lived survived", testRunCheck(camundaUrl, 3))
+}
+
+func TestCleanupTenantRules(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	wg := &sync.WaitGroup{}
+	defer wg.Wait()
+	defer cancel()
+
+	_, camundaPgIp, _, err := docker.PostgresWithNetwork(ctx, wg, "camunda")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	camundaUrl, err := docker.Camunda(ctx, wg, camundaPgIp, "5432")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	for _, owner := range []string{"default_owner", "contract_owner", "exempt_owner"} {
+		processId, err := deployProcess(camundaUrl, owner, createBlankProcessWithKey("tenant_test"), "<svg/>", owner, "test")
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		t.Run("start processes of "+owner, testStartProcesses(camundaUrl, processId, 3))
+	}
+	t.Run("check created instances", testRunCheck(camundaUrl, 9))
+
+	time.Sleep(3 * time.Second)
+
+	t.Run("run cleanup", func(t *testing.T) {
+		err := pkg.RunCleanup(context.Background(), &configuration.ConfigStruct{
+			EngineUrl: camundaUrl,
+			MaxAge:    "2s",
+			TenantRetentionRules: []configuration.TenantRetentionRule{
+				{TenantId: "contract_owner", MaxAge: "10m"},
+			},
+			ExemptTenants: []string{"exempt_owner"},
+			BatchSize:     2,
+			Location:      "Europe/Berlin",
+		}, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+	})
+	t.Run("check contract and exempt tenant survived", testRunCheck(camundaUrl, 6))
+}
+
+func TestCleanupDryRun(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	wg := &sync.WaitGroup{}
+	defer wg.Wait()
+	defer cancel()
+
+	_, camundaPgIp, _, err := docker.PostgresWithNetwork(ctx, wg, "camunda")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	camundaUrl, err := docker.Camunda(ctx, wg, camundaPgIp, "5432")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	processId, err := deployProcess(camundaUrl, "dry_run", createBlankProcessWithKey("dry_run_test"), "<svg/>", "owner", "test")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	t.Run("start old processes", testStartProcesses(camundaUrl, processId, 5))
+	time.Sleep(3 * time.Second)
+	t.Run("start young processes", testStartProcesses(camundaUrl, processId, 2))
+
+	for _, filterLocally := range []bool{false, true} {
+		t.Run("run dry-run cleanup filter locally "+strconv.FormatBool(filterLocally), func(t *testing.T) {
+			var err error
+			logs := captureLog(func() {
+				err = pkg.RunCleanup(context.Background(), &configuration.ConfigStruct{
+					EngineUrl:     camundaUrl,
+					MaxAge:        "2s",
+					BatchSize:     2,
+					FilterLocally: filterLocally,
+					Location:      "Europe/Berlin",
+					DryRun:        true,
+					DryRunListIds: true,
+				}, nil)
+			})
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			//5 candidates are listed over 3 pages
+			if !strings.Contains(logs, `DRY-RUN: would delete 5 instances of process definition key="dry_run_test" tenant="owner"`) ||
+				!strings.Contains(logs, "DRY-RUN: would delete 5 instances in total") {
+				t.Error(logs)
+			}
+			ids := regexp.MustCompile(`DRY-RUN: ids: (\S*)`).FindStringSubmatch(logs)
+			if ids == nil || len(strings.Split(ids[1], ",")) != 5 {
+				t.Error(logs)
+			}
+		})
+		t.Run("check nothing removed", testRunCheck(camundaUrl, 7))
+	}
+}
+
+// captureLog returns everything f writes to the standard logger
+func captureLog(f func()) string {
+	logs := &strings.Builder{}
+	log.SetOutput(logs)
+	defer log.SetOutput(os.Stderr)
+	f()
+	return logs.String()
+}
+
 func testCleanup(batchSize int, deleteCount int, expectSurvivor bool, filterLocally bool) func(t *testing.T) {
+	return testCleanupWithStrategy(batchSize, deleteCount, expectSurvivor, filterLocally, pkg.DeleteStrategySingle)
+}
+
+func testCleanupWithStrategy(batchSize int, deleteCount int, expectSurvivor bool, filterLocally bool, deleteStrategy string) func(t *testing.T) {
 	return func(t *testing.T) {
 		ctx, cancel := context.WithCancel(context.Background())
 		wg := &sync.WaitGroup{}
@@ -88,7 +273,7 @@ func testCleanup(batchSize int, deleteCount int, expectSurvivor bool, filterLoca
 		t.Run("start process n times", testStartProcesses(camundaUrl, processId, deleteCount))
 		t.Run("check n created instances", testRunCheck(camundaUrl, deleteCount))
 
-		t.Run("run cleanup 10m", testRunCleanup(camundaUrl, "10m", batchSize, filterLocally))
+		t.Run("run cleanup 10m", testRunCleanup(camundaUrl, "10m", batchSize, filterLocally, deleteStrategy))
 		t.Run("check after 10m cleanup", testRunCheck(camundaUrl, deleteCount))
 
 		time.Sleep(2 * time.Second)
@@ -100,20 +285,21 @@ func testCleanup(batchSize int, deleteCount int, expectSurvivor bool, filterLoca
 		if expectSurvivor {
 			expectedCount = 1
 		}
-		t.Run("run cleanup 2s", testRunCleanup(camundaUrl, "2s", batchSize, filterLocally))
+		t.Run("run cleanup 2s", testRunCleanup(camundaUrl, "2s", batchSize, filterLocally, deleteStrategy))
 		t.Run("check 2s cleanup", testRunCheck(camundaUrl, expectedCount))
 	}
 }
 
-func testRunCleanup(camundaUrl string, maxAge string, batchSize int, filterLocally bool) func(t *testing.T) {
+func testRunCleanup(camundaUrl string, maxAge string, batchSize int, filterLocally bool, deleteStrategy string) func(t *testing.T) {
 	return func(t *testing.T) {
-		err := pkg.RunCleanup(&configuration.ConfigStruct{
-			EngineUrl:     camundaUrl,
-			MaxAge:        maxAge,
-			BatchSize:     batchSize,
-			FilterLocally: filterLocally,
-			Location:      "Europe/Berlin",
-		})
+		err := pkg.RunCleanup(context.Background(), &configuration.ConfigStruct{
+			EngineUrl:      camundaUrl,
+			MaxAge:         maxAge,
+			BatchSize:      batchSize,
+			FilterLocally:  filterLocally,
+			DeleteStrategy: deleteStrategy,
+			Location:       "Europe/Berlin",
+		}, nil)
 		if err != nil {
 			t.Error(err)
 			return
@@ -125,7 +311,7 @@ func testRunCheck(camundaUrl string, expectedCount int) func(t *testing.T) {
 	return func(t *testing.T) {
 		count, err := camunda.New(&configuration.ConfigStruct{
 			EngineUrl: camundaUrl,
-		}).ListHistoryCount(true)
+		}).ListHistoryCount(context.Background(), true)
 		if err != nil {
 			t.Error(err)
 			return
@@ -167,8 +353,12 @@ func testCreateProcess(url string, id *string) func(t *testing.T) {
 }
 
 func createBlankProcess() string {
+	return createBlankProcessWithKey("id_" + strconv.FormatInt(time.Now().Unix(), 10))
+}
+
+func createBlankProcessWithKey(key string) string {
 	templ := `<bpmn:definitions xmlns:xsi='http://www.w3.org/2001/XMLSchema-instance' xmlns:bpmn='http://www.omg.org/spec/BPMN/20100524/MODEL' xmlns:bpmndi='http://www.omg.org/spec/BPMN/20100524/DI' xmlns:dc='http://www.omg.org/spec/DD/20100524/DC' id='Definitions_1' targetNamespace='http://bpmn.io/schema/bpmn'><bpmn:process id='PROCESSID' isExecutable='true'><bpmn:startEvent id='StartEvent_1'/></bpmn:process><bpmndi:BPMNDiagram id='BPMNDiagram_1'><bpmndi:BPMNPlane id='BPMNPlane_1' bpmnElement='PROCESSID'><bpmndi:BPMNShape id='_BPMNShape_StartEvent_2' bpmnElement='StartEvent_1'><dc:Bounds x='173' y='102' width='36' height='36'/></bpmndi:BPMNShape></bpmndi:BPMNPlane></bpmndi:BPMNDiagram></bpmn:definitions>`
-	return strings.Replace(templ, "PROCESSID", "id_"+strconv.FormatInt(time.Now().Unix(), 10), 1)
+	return strings.Replace(templ, "PROCESSID", key, 1)
 }
 
 func deployProcess(engineUrl string, name string, xml string, svg string, owner string, source string) (definitionId string, err error) {
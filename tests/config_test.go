@@ -0,0 +1,407 @@
+/*
+ * Copyright 2020 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tests
+
+import (
+	"context"
+	"errors"
+	"github.com/SENERGY-Platform/process-history-cleanup/pkg/configuration"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestLoadDefaultConfig(t *testing.T) {
+	t.Setenv("ENGINE_URL", "http://engine:8080/engine-rest")
+	config, err := configuration.Load("../config.json")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if config.EngineUrl != "http://engine:8080/engine-rest" || config.BatchSize != 100 {
+		t.Error(config.EngineUrl, config.BatchSize)
+	}
+}
+
+func TestConfigValidation(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.json")
+	err := os.WriteFile(file, []byte(`{
+		"engine_url": "engine:8080",
+		"max_age": "7 days",
+		"retention_rules": [{"process_definition_key": "a", "max_age": "1d", "maxage": "2d"}],
+		"batch_size": 0,
+		"location": "Mars/Olympus_Mons",
+		"intervall": "1d"
+	}`), 0644)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	t.Setenv("DELETE_CONCURRENCY", "four")
+	t.Setenv("DRY_RUN", "yes please")
+
+	_, err = configuration.Load(file)
+	if !errors.Is(err, configuration.ErrInvalidConfig) || !errors.Is(err, configuration.ErrInvalidDuration) {
+		t.Error(err)
+		return
+	}
+	for _, expected := range []string{
+		"unknown field intervall",
+		"unknown field retention_rules[0].maxage",
+		"environment variable DELETE_CONCURRENCY",
+		"environment variable DRY_RUN",
+		"engine_url",
+		"max_age",
+		"batch_size",
+		"location",
+	} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Error("missing", expected, "in", err)
+		}
+	}
+	if strings.Contains(err.Error(), "retention_rules[0].max_age") {
+		t.Error(err)
+	}
+}
+
+func TestConfigValidationSettings(t *testing.T) {
+	err := configuration.Validate(&configuration.ConfigStruct{
+		EngineUrl:              "http://localhost:8080",
+		EngineAuth:             configuration.AuthOAuth2,
+		EngineClientSecret:     "secret",
+		EngineTlsMinVersion:    "1.4",
+		Backend:                "mysql",
+		MaxAge:                 "7d",
+		RetentionRules:         []configuration.RetentionRule{{ProcessDefinitionKey: "a", MaxAge: "1d"}, {ProcessDefinitionKey: "a", MaxAge: "2d"}, {MaxAge: "1d"}},
+		ExemptTenants:          []string{"t1"},
+		TenantRetentionRules:   []configuration.TenantRetentionRule{{TenantId: "t1", MaxAge: "1d"}},
+		DecisionRetentionRules: []configuration.DecisionRetentionRule{{MaxAge: "1d"}},
+		BatchSize:              10,
+		Location:               "Europe/Berlin",
+		Schedule:               "0 25 * * *",
+		MaintenanceWindows:     []configuration.MaintenanceWindow{{Weekdays: []string{"mon", "someday"}, Start: "22:00", End: "6"}},
+		DeleteStrategy:         "bulk",
+		ArchiveSink:            configuration.ArchiveSinkS3,
+		ArchiveS3Endpoint:      "minio:9000",
+		UserOperationLogMaxAge: "30d",
+	})
+	if !errors.Is(err, configuration.ErrInvalidConfig) {
+		t.Error(err)
+		return
+	}
+	for _, expected := range []string{
+		"backend",
+		"engine_token_url",
+		"engine_client_id",
+		"engine_tls_min_version",
+		"delete_strategy",
+		"archive_s3_endpoint",
+		"archive_s3_bucket",
+		"schedule",
+		"maintenance_windows[0].weekdays[1]",
+		"maintenance_windows[0].end",
+		"retention_rules[1].process_definition_key: duplicate",
+		"retention_rules[2].process_definition_key: missing",
+		"tenant_retention_rules[0].tenant_id: duplicate",
+		"decision_retention_rules[0].decision_definition_key: missing",
+		"user_operation_log_max_age: only supported by the postgres backend",
+	} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Error("missing", expected, "in", err)
+		}
+	}
+	for _, unexpected := range []string{"engine_client_secret", "maintenance_windows[0].start", "weekdays[0]"} {
+		if strings.Contains(err.Error(), unexpected) {
+			t.Error("unexpected", unexpected, "in", err)
+		}
+	}
+}
+
+func TestConfigValidationPostgresBackend(t *testing.T) {
+	err := configuration.Validate(&configuration.ConfigStruct{
+		Backend:      "postgres",
+		MaxAge:       "P1M",
+		BatchSize:    10,
+		Location:     "Europe/Berlin",
+		Interval:     "-",
+		JobLogMaxAge: "30d",
+	})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestLoadConfigFormats(t *testing.T) {
+	files := map[string]string{
+		"config.yaml": `
+engine_url: http://localhost:8080/engine-rest
+max_age: 7d
+retention_rules:
+  - process_definition_key: short
+    max_age: 1d
+  - process_definition_key: long
+    max_age: P1Y
+exempt_tenants: [a, b]
+batch_size: 50
+location: Europe/Berlin
+dry_run: true
+`,
+		"config.toml": `
+engine_url = "http://localhost:8080/engine-rest"
+max_age = "7d"
+exempt_tenants = ["a", "b"]
+batch_size = 50
+location = "Europe/Berlin"
+dry_run = true
+
+[[retention_rules]]
+process_definition_key = "short"
+max_age = "1d"
+
+[[retention_rules]]
+process_definition_key = "long"
+max_age = "P1Y"
+`,
+	}
+	for name, content := range files {
+		t.Run(name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), name)
+			err := os.WriteFile(file, []byte(content), 0644)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			t.Setenv("BATCH_SIZE", "20")
+			config, err := configuration.Load(file)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if config.MaxAge != "7d" || config.BatchSize != 20 || !config.DryRun || len(config.ExemptTenants) != 2 {
+				t.Errorf("%#v", config)
+			}
+			if len(config.RetentionRules) != 2 || config.RetentionRules[1].ProcessDefinitionKey != "long" || config.RetentionRules[1].MaxAge != "P1Y" {
+				t.Errorf("%#v", config.RetentionRules)
+			}
+		})
+	}
+}
+
+func TestYamlConfigUnknownField(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.yml")
+	err := os.WriteFile(file, []byte("engine_url: http://localhost:8080\nmax_age: 7d\nbatch_size: 10\nretention_rules:\n  - process_definition_key: a\n    max_age: 1d\n    tenant: b\n"), 0644)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	_, err = configuration.Load(file)
+	if !errors.Is(err, configuration.ErrInvalidConfig) || !strings.Contains(err.Error(), "unknown field retention_rules[0].tenant") {
+		t.Error(err)
+	}
+}
+
+func TestLoadConfigUnquotedScalars(t *testing.T) {
+	files := map[string]string{
+		"config.yaml": `
+engine_url: http://localhost:8080/engine-rest
+engine_tls_min_version: 1.0
+api_port: 8080
+max_age: 7d
+exempt_tenants: [1, 2]
+batch_size: 50
+location: Europe/Berlin
+`,
+		"config.toml": `
+engine_url = "http://localhost:8080/engine-rest"
+engine_tls_min_version = 1.0
+api_port = 8080
+max_age = "7d"
+exempt_tenants = [1, 2]
+batch_size = 50
+location = "Europe/Berlin"
+`,
+	}
+	for name, content := range files {
+		t.Run(name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), name)
+			err := os.WriteFile(file, []byte(content), 0644)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			config, err := configuration.Load(file)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if config.EngineTlsMinVersion != "1.0" || config.ApiPort != "8080" || config.BatchSize != 50 || strings.Join(config.ExemptTenants, ",") != "1,2" {
+				t.Errorf("%#v", config)
+			}
+		})
+	}
+}
+
+func TestNestedEnvironmentVars(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "config.json")
+	err := os.WriteFile(file, []byte(`{
+		"engine_url": "http://localhost:8080",
+		"max_age": "7d",
+		"retention_rules": [{"process_definition_key": "a", "max_age": "1d"}],
+		"batch_size": 10
+	}`), 0644)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	secretFile := filepath.Join(dir, "conn")
+	err = os.WriteFile(secretFile, []byte("postgres://user:hunter2@db/camunda\n"), 0600)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	t.Setenv("RETENTION_RULES_0_MAX_AGE", "2d")
+	t.Setenv("RETENTION_RULES_1_PROCESS_DEFINITION_KEY", "b")
+	t.Setenv("RETENTION_RULES_1_MAX_AGE", "3d")
+	t.Setenv("MAINTENANCE_WINDOWS_0_WEEKDAYS", "mon, tue")
+	t.Setenv("MAINTENANCE_WINDOWS_0_START", "22:00")
+	t.Setenv("MAINTENANCE_WINDOWS_0_END", "04:00")
+	t.Setenv("ENGINE_SCOPES", "read,admin")
+	t.Setenv("ENGINE_SCOPES_1", "write")
+	t.Setenv("ENGINE_PASSWORD", "hunter2")
+	t.Setenv("POSTGRES_CONN_STR_FILE", secretFile)
+
+	logs := &strings.Builder{}
+	log.SetOutput(logs)
+	defer log.SetOutput(os.Stderr)
+
+	config, err := configuration.Load(file)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(config.RetentionRules) != 2 || config.RetentionRules[0] != (configuration.RetentionRule{ProcessDefinitionKey: "a", MaxAge: "2d"}) || config.RetentionRules[1] != (configuration.RetentionRule{ProcessDefinitionKey: "b", MaxAge: "3d"}) {
+		t.Errorf("%#v", config.RetentionRules)
+	}
+	if len(config.MaintenanceWindows) != 1 || strings.Join(config.MaintenanceWindows[0].Weekdays, ",") != "mon,tue" || config.MaintenanceWindows[0].Start != "22:00" {
+		t.Errorf("%#v", config.MaintenanceWindows)
+	}
+	if strings.Join(config.EngineScopes, ",") != "read,write" {
+		t.Error(config.EngineScopes)
+	}
+	if config.EnginePassword != "hunter2" || config.PostgresConnStr != "postgres://user:hunter2@db/camunda" {
+		t.Error(config.EnginePassword, config.PostgresConnStr)
+	}
+	if strings.Contains(logs.String(), "hunter2") || !strings.Contains(logs.String(), "ENGINE_PASSWORD = ***") || !strings.Contains(logs.String(), "POSTGRES_CONN_STR_FILE") {
+		t.Error(logs.String())
+	}
+}
+
+func TestEnvironmentVarsWithValueAndFile(t *testing.T) {
+	t.Setenv("ARCHIVE_S3_SECRET_KEY", "secret")
+	t.Setenv("ARCHIVE_S3_SECRET_KEY_FILE", "/run/secrets/s3")
+	t.Setenv("RETENTION_RULES_0_MAX_AGE", "2d")
+	t.Setenv("EXEMPT_TENANTS_1", "b")
+	config := &configuration.ConfigStruct{}
+	err := configuration.HandleEnvironmentVars(config)
+	if !errors.Is(err, configuration.ErrInvalidConfig) || !strings.Contains(err.Error(), "ARCHIVE_S3_SECRET_KEY_FILE") {
+		t.Error(err)
+	}
+	//new elements have to be contiguous
+	if !strings.Contains(err.Error(), "EXEMPT_TENANTS_1: missing element EXEMPT_TENANTS_0") {
+		t.Error(err)
+	}
+	if len(config.RetentionRules) != 1 || config.RetentionRules[0].MaxAge != "2d" || len(config.ExemptTenants) != 0 {
+		t.Errorf("%#v", config)
+	}
+}
+
+func TestSparseEnvironmentIndexes(t *testing.T) {
+	t.Setenv("RETENTION_RULES_1_MAX_AGE", "2d")
+	config := &configuration.ConfigStruct{}
+	err := configuration.HandleEnvironmentVars(config)
+	if !errors.Is(err, configuration.ErrInvalidConfig) || !strings.Contains(err.Error(), "missing element RETENTION_RULES_0") {
+		t.Error(err)
+	}
+	if len(config.RetentionRules) != 0 {
+		t.Errorf("%#v", config.RetentionRules)
+	}
+
+	//existing elements may be addressed in any order
+	config = &configuration.ConfigStruct{RetentionRules: []configuration.RetentionRule{{ProcessDefinitionKey: "a"}, {ProcessDefinitionKey: "b"}}}
+	err = configuration.HandleEnvironmentVars(config)
+	if err != nil || config.RetentionRules[1].MaxAge != "2d" {
+		t.Error(err, config.RetentionRules)
+	}
+}
+
+func TestWatchConfig(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	write := func(maxAge string) {
+		err := os.WriteFile(file, []byte("engine_url: http://localhost:8080\nbatch_size: 10\ninterval: 1h\nmax_age: "+maxAge+"\n"), 0644)
+		if err != nil {
+			t.Error(err)
+		}
+	}
+	write("7d")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	configs, err := configuration.Watch(ctx, file)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	expect := func(maxAge string) {
+		select {
+		case config := <-configs:
+			if config.MaxAge != maxAge {
+				t.Error(maxAge, config.MaxAge)
+			}
+		case <-time.After(5 * time.Second):
+			t.Error("missing reload", maxAge)
+		}
+	}
+
+	write("14d")
+	expect("14d")
+
+	//invalid configs are skipped
+	write("14 days")
+	select {
+	case config := <-configs:
+		t.Error("unexpected reload", config.MaxAge)
+	case <-time.After(2 * time.Second):
+	}
+
+	write("1mo")
+	expect("1mo")
+
+	err = syscall.Kill(os.Getpid(), syscall.SIGHUP)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	expect("1mo")
+
+	cancel()
+	for range configs {
+	}
+}
@@ -0,0 +1,130 @@
+/*
+ * Copyright 2020 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tests
+
+import (
+	"context"
+	"github.com/SENERGY-Platform/process-history-cleanup/pkg"
+	"github.com/SENERGY-Platform/process-history-cleanup/pkg/configuration"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDecisionCleanup(t *testing.T) {
+	engine := newFakeCamunda()
+	for i := 0; i < 7; i++ {
+		engine.addDecision("short_old_"+strconv.Itoa(i), "short", 2*time.Hour)
+		engine.addDecision("long_old_"+strconv.Itoa(i), "long", 2*time.Hour)
+		engine.addDecision("other_old_"+strconv.Itoa(i), "other", 48*time.Hour)
+	}
+	engine.addDecision("short_young", "short", time.Minute)
+	engine.addDecision("other_young", "other", time.Hour)
+	server := httptest.NewServer(engine)
+	defer server.Close()
+
+	config := &configuration.ConfigStruct{
+		EngineUrl:      server.URL,
+		MaxAge:         "10m",
+		BatchSize:      3,
+		Location:       "Europe/Berlin",
+		DecisionMaxAge: "24h",
+		DecisionRetentionRules: []configuration.DecisionRetentionRule{
+			{DecisionDefinitionKey: "short", MaxAge: "1h"},
+			{DecisionDefinitionKey: "long", MaxAge: "720h"},
+		},
+		DryRun: true,
+	}
+
+	check := func(key string, expected int) {
+		if count := engine.decisionCount(key); count != expected {
+			t.Error(key, count, expected)
+		}
+	}
+
+	t.Run("dry-run", func(t *testing.T) {
+		err := pkg.RunCleanup(context.Background(), config, nil)
+		if err != nil {
+			t.Error(err)
+		}
+		check("short", 8)
+		check("long", 7)
+		check("other", 8)
+	})
+
+	t.Run("remove", func(t *testing.T) {
+		config.DryRun = false
+		err := pkg.RunCleanup(context.Background(), config, nil)
+		if err != nil {
+			t.Error(err)
+		}
+		check("short", 1)
+		check("long", 7)
+		check("other", 1)
+	})
+}
+
+func TestDecisionTenantRules(t *testing.T) {
+	run := func(name string, decisionMaxAge string, expected string) {
+		t.Run(name, func(t *testing.T) {
+			engine := newFakeCamunda()
+			for i := 0; i < 3; i++ {
+				engine.addTenantDecision("exempt_short_"+strconv.Itoa(i), "short", "exempt", 48*time.Hour)
+			}
+			engine.addTenantDecision("exempt_other", "other", "exempt", 48*time.Hour)
+			engine.addTenantDecision("ruled_short_young", "short", "ruled", 2*time.Hour)
+			engine.addTenantDecision("ruled_short_old", "short", "ruled", 60*24*time.Hour)
+			engine.addTenantDecision("ruled_other_old", "other", "ruled", 60*24*time.Hour)
+			engine.addTenantDecision("tenant_short", "short", "tenant", 2*time.Hour)
+			engine.addTenantDecision("tenant_other", "other", "tenant", 48*time.Hour)
+			engine.addDecision("without_tenant_other", "other", 48*time.Hour)
+			server := httptest.NewServer(engine)
+			defer server.Close()
+
+			err := pkg.RunCleanup(context.Background(), &configuration.ConfigStruct{
+				EngineUrl:      server.URL,
+				MaxAge:         "10m",
+				BatchSize:      2,
+				Location:       "Europe/Berlin",
+				DecisionMaxAge: decisionMaxAge,
+				DecisionRetentionRules: []configuration.DecisionRetentionRule{
+					{DecisionDefinitionKey: "short", MaxAge: "1h"},
+				},
+				TenantRetentionRules: []configuration.TenantRetentionRule{{TenantId: "ruled", MaxAge: "30d"}},
+				ExemptTenants:        []string{"exempt"},
+			}, nil)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			remaining := []string{}
+			for _, decision := range engine.decisions {
+				remaining = append(remaining, decision.Id)
+			}
+			sort.Strings(remaining)
+			if strings.Join(remaining, ",") != expected {
+				t.Error(remaining)
+			}
+		})
+	}
+	run("with decision max age", "24h", "exempt_other,exempt_short_0,exempt_short_1,exempt_short_2,ruled_short_young")
+	//tenant rules do not enable the cleanup of decisions without rule
+	run("without decision max age", "", "exempt_other,exempt_short_0,exempt_short_1,exempt_short_2,ruled_other_old,ruled_short_young,tenant_other,without_tenant_other")
+}
@@ -0,0 +1,78 @@
+/*
+ * Copyright 2020 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tests
+
+import (
+	"context"
+	"github.com/SENERGY-Platform/process-history-cleanup/pkg"
+	"github.com/SENERGY-Platform/process-history-cleanup/pkg/configuration"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDryRunReport(t *testing.T) {
+	engine := newFakeCamunda()
+	//old instances of both groups alternate, so that every page contains both
+	for i := 0; i < 4; i++ {
+		engine.addFinished("a_"+strconv.Itoa(i), "a", "t1", 10*time.Hour-time.Duration(2*i)*time.Minute)
+	}
+	for i := 0; i < 3; i++ {
+		engine.addFinished("b_"+strconv.Itoa(i), "b", "", 10*time.Hour-time.Duration(2*i+1)*time.Minute)
+	}
+	engine.addFinished("a_young", "a", "t1", time.Minute)
+	engine.addFinished("b_young", "b", "", time.Minute)
+	server := httptest.NewServer(engine)
+	defer server.Close()
+
+	for _, filterLocally := range []bool{false, true} {
+		t.Run("filter locally "+strconv.FormatBool(filterLocally), func(t *testing.T) {
+			var err error
+			logs := captureLog(func() {
+				err = pkg.RunCleanup(context.Background(), &configuration.ConfigStruct{
+					EngineUrl:     server.URL,
+					MaxAge:        "1h",
+					BatchSize:     3,
+					FilterLocally: filterLocally,
+					Location:      "Europe/Berlin",
+					DryRun:        true,
+					DryRunListIds: true,
+				}, nil)
+			})
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			for _, expected := range []string{
+				"DRY-RUN: would delete 4 instances of process definition key=\"a\" tenant=\"t1\"\n",
+				"DRY-RUN: ids: a_0,a_1,a_2,a_3\n",
+				"DRY-RUN: would delete 3 instances of process definition key=\"b\" tenant=\"\"\n",
+				"DRY-RUN: ids: b_0,b_1,b_2\n",
+				"DRY-RUN: would delete 7 instances in total\n",
+			} {
+				if !strings.Contains(logs, expected) {
+					t.Error("missing", expected, "in", logs)
+				}
+			}
+			if engine.count() != 9 {
+				t.Error(engine.count())
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+/*
+ * Copyright 2020 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tests
+
+import (
+	"errors"
+	"github.com/SENERGY-Platform/process-history-cleanup/pkg/configuration"
+	"github.com/SENERGY-Platform/process-history-cleanup/pkg/scheduler"
+	"testing"
+	"time"
+)
+
+func TestParseDuration(t *testing.T) {
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+	now := time.Date(2024, 3, 31, 12, 0, 0, 0, berlin)
+	for text, expected := range map[string]time.Time{
+		"0":       now,
+		"10m":     now.Add(-10 * time.Minute),
+		"36h":     now.Add(-36 * time.Hour),
+		"1.5h":    now.Add(-90 * time.Minute),
+		"7d":      time.Date(2024, 3, 24, 12, 0, 0, 0, berlin),
+		"2w":      time.Date(2024, 3, 17, 12, 0, 0, 0, berlin),
+		"1mo":     time.Date(2024, 2, 31, 12, 0, 0, 0, berlin), //normalized like time.AddDate
+		"1y":      time.Date(2023, 3, 31, 12, 0, 0, 0, berlin),
+		"1y6mo":   time.Date(2022, 9, 31, 12, 0, 0, 0, berlin),
+		"1d12h":   time.Date(2024, 3, 30, 0, 0, 0, 0, berlin), //the calendar day keeps the wall clock across the dst change, hours are exact
+		"P30D":    time.Date(2024, 3, 1, 12, 0, 0, 0, berlin),
+		"P1Y2M":   time.Date(2023, 1, 31, 12, 0, 0, 0, berlin),
+		"P1W":     time.Date(2024, 3, 24, 12, 0, 0, 0, berlin),
+		"PT12H":   now.Add(-12 * time.Hour),
+		"P1DT30M": time.Date(2024, 3, 30, 11, 30, 0, 0, berlin),
+		"PT1.5S":  now.Add(-1500 * time.Millisecond),
+	} {
+		duration, err := configuration.ParseDuration(text)
+		if err != nil {
+			t.Error(text, err)
+			continue
+		}
+		if before := duration.Before(now, berlin); !before.Equal(expected) {
+			t.Error(text, expected, before)
+		}
+	}
+
+	for _, text := range []string{"", "7", "1.5d", "7days", "-1d", "P", "PT", "P1H", "1d 2h"} {
+		_, err := configuration.ParseDuration(text)
+		if !errors.Is(err, configuration.ErrInvalidDuration) {
+			t.Error(text, err)
+		}
+	}
+}
+
+func TestIntervalSchedule(t *testing.T) {
+	schedule, err := scheduler.FromConfig(&configuration.ConfigStruct{
+		Interval: "1d",
+		Location: "Europe/Berlin",
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+	next := schedule.Next(time.Date(2024, 3, 30, 3, 0, 0, 0, berlin))
+	expected := time.Date(2024, 3, 31, 3, 0, 0, 0, berlin)
+	if !next.Equal(expected) {
+		t.Error(expected, next)
+	}
+}
@@ -0,0 +1,81 @@
+/*
+ * Copyright 2020 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tests
+
+import (
+	"context"
+	"github.com/SENERGY-Platform/process-history-cleanup/pkg"
+	"github.com/SENERGY-Platform/process-history-cleanup/pkg/configuration"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestEngineReusedBetweenRuns(t *testing.T) {
+	engine := newFakeCamunda()
+	server := httptest.NewUnstartedServer(engine)
+	mux := sync.Mutex{}
+	opened := 0
+	closed := 0
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		mux.Lock()
+		defer mux.Unlock()
+		switch state {
+		case http.StateNew:
+			opened++
+		case http.StateClosed:
+			closed++
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	config := &configuration.ConfigStruct{
+		EngineUrl:         server.URL,
+		MaxAge:            "1h",
+		BatchSize:         10,
+		DeleteConcurrency: 1,
+		Location:          "Europe/Berlin",
+	}
+	camunda, closeEngine, err := pkg.NewEngine(config, nil)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	for run := 0; run < 3; run++ {
+		engine.addFinished("old_"+strconv.Itoa(run), "test", "", 2*time.Hour)
+		err = pkg.RunCleanupWithEngine(context.Background(), config, camunda, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+	}
+	if engine.count() != 0 {
+		t.Error(engine.count())
+	}
+	closeEngine()
+	time.Sleep(200 * time.Millisecond)
+	mux.Lock()
+	defer mux.Unlock()
+	if opened != 1 || closed != 1 {
+		t.Error("expect one reused connection that is closed by the engine", opened, closed)
+	}
+}
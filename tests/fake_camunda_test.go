@@ -0,0 +1,405 @@
+/*
+ * Copyright 2020 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tests
+
+import (
+	"encoding/json"
+	"github.com/SENERGY-Platform/process-history-cleanup/pkg/camunda"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fakeCamunda is an in memory stand-in for the parts of the camunda rest api used by the cleanup
+type fakeCamunda struct {
+	mux       sync.Mutex
+	instances []camunda.HistoricProcessInstance
+	variables []camunda.HistoricVariableInstance
+	decisions []camunda.HistoricDecisionInstance
+	batches   []camunda.HistoricBatch
+	failIds   map[string]bool
+	deletes   int
+	inFlight  int
+	maxFlight int
+	listed    map[string]int //instances per tenant returned by cleanup list requests
+}
+
+func newFakeCamunda() *fakeCamunda {
+	return &fakeCamunda{failIds: map[string]bool{}, listed: map[string]int{}}
+}
+
+// addFinished adds an instance that finished age ago
+func (this *fakeCamunda) addFinished(id string, key string, tenant string, age time.Duration) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	this.instances = append(this.instances, camunda.HistoricProcessInstance{
+		Id:                   id,
+		ProcessDefinitionKey: key,
+		TenantId:             tenant,
+		EndTime:              time.Now().Add(-age).Format(camunda.CamundaTimeFormat),
+		State:                "COMPLETED",
+	})
+}
+
+func (this *fakeCamunda) count() int {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	return len(this.instances)
+}
+
+func (this *fakeCamunda) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	path := strings.TrimPrefix(request.URL.Path, "/engine-rest")
+	//like the default request line limit of tomcat
+	if len(request.URL.RawQuery) > 8192 {
+		http.Error(writer, "request uri too long", http.StatusRequestURITooLong)
+		return
+	}
+	switch {
+	case request.Method == http.MethodPost && path == "/history/process-instance":
+		query := camunda.HistoricProcessInstanceQuery{}
+		err := json.NewDecoder(request.Body).Decode(&query)
+		if err != nil {
+			http.Error(writer, err.Error(), http.StatusBadRequest)
+			return
+		}
+		params := request.URL.Query()
+		params.Set("processInstanceIds", strings.Join(query.ProcessInstanceIds, ","))
+		this.mux.Lock()
+		list := this.filter(params)
+		this.mux.Unlock()
+		limit, err := strconv.Atoi(params.Get("maxResults"))
+		if err != nil {
+			limit = len(list)
+		}
+		json.NewEncoder(writer).Encode(list[:min(limit, len(list))])
+	case request.Method == http.MethodGet && path == "/history/process-instance":
+		this.mux.Lock()
+		list := this.filter(request.URL.Query())
+		this.mux.Unlock()
+		offset, _ := strconv.Atoi(request.URL.Query().Get("firstResult"))
+		limit, err := strconv.Atoi(request.URL.Query().Get("maxResults"))
+		if err != nil {
+			limit = len(list)
+		}
+		list = list[min(offset, len(list)):min(offset+limit, len(list))]
+		if request.URL.Query().Get("sortBy") != "tenantId" {
+			this.mux.Lock()
+			for _, instance := range list {
+				this.listed[instance.TenantId]++
+			}
+			this.mux.Unlock()
+		}
+		json.NewEncoder(writer).Encode(list)
+	case request.Method == http.MethodGet && path == "/history/process-instance/count":
+		this.mux.Lock()
+		count := len(this.filter(request.URL.Query()))
+		this.mux.Unlock()
+		json.NewEncoder(writer).Encode(camunda.Count{Count: int64(count)})
+	case request.Method == http.MethodDelete && strings.HasPrefix(path, "/history/process-instance/"):
+		this.remove(writer, strings.TrimPrefix(path, "/history/process-instance/"))
+	case request.Method == http.MethodGet && path == "/history/variable-instance":
+		this.mux.Lock()
+		list := this.filterVariables(request.URL.Query())
+		this.mux.Unlock()
+		offset, _ := strconv.Atoi(request.URL.Query().Get("firstResult"))
+		limit, err := strconv.Atoi(request.URL.Query().Get("maxResults"))
+		if err != nil {
+			limit = len(list)
+		}
+		list = list[min(offset, len(list)):min(offset+limit, len(list))]
+		json.NewEncoder(writer).Encode(list)
+	case request.Method == http.MethodDelete && strings.HasPrefix(path, "/history/variable-instance/"):
+		this.removeVariable(writer, strings.TrimPrefix(path, "/history/variable-instance/"))
+	case request.Method == http.MethodGet && path == "/history/decision-instance":
+		this.mux.Lock()
+		list := this.filterDecisions(request.URL.Query())
+		this.mux.Unlock()
+		offset, _ := strconv.Atoi(request.URL.Query().Get("firstResult"))
+		limit, err := strconv.Atoi(request.URL.Query().Get("maxResults"))
+		if err != nil {
+			limit = len(list)
+		}
+		list = list[min(offset, len(list)):min(offset+limit, len(list))]
+		json.NewEncoder(writer).Encode(list)
+	case request.Method == http.MethodGet && path == "/history/decision-instance/count":
+		this.mux.Lock()
+		count := len(this.filterDecisions(request.URL.Query()))
+		this.mux.Unlock()
+		json.NewEncoder(writer).Encode(camunda.Count{Count: int64(count)})
+	case request.Method == http.MethodPost && path == "/history/decision-instance/delete":
+		this.removeDecisions(writer, request)
+	case request.Method == http.MethodGet && path == "/history/batch":
+		this.mux.Lock()
+		list := append([]camunda.HistoricBatch{}, this.batches...)
+		this.mux.Unlock()
+		sort.SliceStable(list, func(i, j int) bool {
+			return list[i].EndTime < list[j].EndTime
+		})
+		offset, _ := strconv.Atoi(request.URL.Query().Get("firstResult"))
+		limit, err := strconv.Atoi(request.URL.Query().Get("maxResults"))
+		if err != nil {
+			limit = len(list)
+		}
+		list = list[min(offset, len(list)):min(offset+limit, len(list))]
+		json.NewEncoder(writer).Encode(list)
+	case request.Method == http.MethodDelete && strings.HasPrefix(path, "/history/batch/"):
+		this.removeBatch(writer, strings.TrimPrefix(path, "/history/batch/"))
+	case request.Method == http.MethodGet && (path == "/deployment" || path == "/history/activity-instance" || path == "/batch/statistics"):
+		json.NewEncoder(writer).Encode([]interface{}{})
+	default:
+		http.Error(writer, "not implemented in fake", http.StatusNotFound)
+	}
+}
+
+func (this *fakeCamunda) remove(writer http.ResponseWriter, id string) {
+	this.mux.Lock()
+	this.inFlight++
+	this.maxFlight = max(this.maxFlight, this.inFlight)
+	this.mux.Unlock()
+	time.Sleep(10 * time.Millisecond)
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	this.inFlight--
+	this.deletes++
+	if this.failIds[id] {
+		http.Error(writer, `{"type":"ProcessEngineException","message":"fake failure"}`, http.StatusInternalServerError)
+		return
+	}
+	for i, instance := range this.instances {
+		if instance.Id == id {
+			this.instances = append(this.instances[:i], this.instances[i+1:]...)
+			writer.WriteHeader(http.StatusNoContent)
+			return
+		}
+	}
+	http.Error(writer, `{"type":"InvalidRequestException","message":"not found"}`, http.StatusNotFound)
+}
+
+// addVariable adds a historic variable instance of the given process instance, which does not have to exist
+func (this *fakeCamunda) addVariable(id string, processInstanceId string) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	this.variables = append(this.variables, camunda.HistoricVariableInstance{
+		Id:                id,
+		Name:              "var",
+		Type:              "String",
+		Value:             "value",
+		ProcessInstanceId: processInstanceId,
+	})
+}
+
+func (this *fakeCamunda) variableCount() int {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	return len(this.variables)
+}
+
+func (this *fakeCamunda) removeVariable(writer http.ResponseWriter, id string) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	for i, variable := range this.variables {
+		if variable.Id == id {
+			this.variables = append(this.variables[:i], this.variables[i+1:]...)
+			writer.WriteHeader(http.StatusNoContent)
+			return
+		}
+	}
+	http.Error(writer, `{"type":"InvalidRequestException","message":"not found"}`, http.StatusNotFound)
+}
+
+// filterVariables supports the processInstanceId filter; the result is sorted by id
+func (this *fakeCamunda) filterVariables(query url.Values) (result []camunda.HistoricVariableInstance) {
+	for _, variable := range this.variables {
+		if id := query.Get("processInstanceId"); id != "" && variable.ProcessInstanceId != id {
+			continue
+		}
+		result = append(result, variable)
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Id < result[j].Id
+	})
+	return result
+}
+
+// addBatch adds a completed historic batch that ended age ago
+func (this *fakeCamunda) addBatch(id string, age time.Duration) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	this.batches = append(this.batches, camunda.HistoricBatch{
+		Batch:   camunda.Batch{Id: id},
+		EndTime: time.Now().Add(-age).Format(camunda.CamundaTimeFormat),
+	})
+}
+
+func (this *fakeCamunda) batchCount() int {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	return len(this.batches)
+}
+
+func (this *fakeCamunda) removeBatch(writer http.ResponseWriter, id string) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	for i, batch := range this.batches {
+		if batch.Id == id {
+			this.batches = append(this.batches[:i], this.batches[i+1:]...)
+			writer.WriteHeader(http.StatusNoContent)
+			return
+		}
+	}
+	http.Error(writer, `{"type":"InvalidRequestException","message":"not found"}`, http.StatusNotFound)
+}
+
+// addDecision adds a historic decision instance that was evaluated age ago
+func (this *fakeCamunda) addDecision(id string, key string, age time.Duration) {
+	this.addTenantDecision(id, key, "", age)
+}
+
+func (this *fakeCamunda) addTenantDecision(id string, key string, tenant string, age time.Duration) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	this.decisions = append(this.decisions, camunda.HistoricDecisionInstance{
+		Id:                    id,
+		DecisionDefinitionId:  key + ":1",
+		DecisionDefinitionKey: key,
+		EvaluationTime:        time.Now().Add(-age).Format(camunda.CamundaTimeFormat),
+		TenantId:              tenant,
+	})
+}
+
+func (this *fakeCamunda) decisionCount(key string) (count int) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	for _, decision := range this.decisions {
+		if decision.DecisionDefinitionKey == key {
+			count++
+		}
+	}
+	return count
+}
+
+// removeDecisions deletes the decisions immediately and returns a batch that is already finished
+func (this *fakeCamunda) removeDecisions(writer http.ResponseWriter, request *http.Request) {
+	body := camunda.DeleteHistoricDecisionInstancesRequest{}
+	err := json.NewDecoder(request.Body).Decode(&body)
+	if err != nil {
+		http.Error(writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	remaining := []camunda.HistoricDecisionInstance{}
+	for _, decision := range this.decisions {
+		if !contains(body.HistoricDecisionInstanceIds, decision.Id) {
+			remaining = append(remaining, decision)
+		}
+	}
+	this.decisions = remaining
+	json.NewEncoder(writer).Encode(camunda.Batch{Id: "batch"})
+}
+
+// filterDecisions supports the query parameters used by camunda.DecisionFilter; the result is sorted by evaluation time
+func (this *fakeCamunda) filterDecisions(query url.Values) (result []camunda.HistoricDecisionInstance) {
+	var before time.Time
+	if query.Get("evaluatedBefore") != "" {
+		before, _ = time.Parse(camunda.CamundaTimeFormat, query.Get("evaluatedBefore"))
+	}
+	for _, decision := range this.decisions {
+		evaluationTime, _ := time.Parse(camunda.CamundaTimeFormat, decision.EvaluationTime)
+		if !before.IsZero() && evaluationTime.After(before) {
+			continue
+		}
+		if keys := query.Get("decisionDefinitionKeyIn"); keys != "" && !contains(strings.Split(keys, ","), decision.DecisionDefinitionKey) {
+			continue
+		}
+		if tenants := query.Get("tenantIdIn"); tenants != "" && !contains(strings.Split(tenants, ","), decision.TenantId) {
+			continue
+		}
+		if query.Get("withoutTenantId") == "true" && decision.TenantId != "" {
+			continue
+		}
+		result = append(result, decision)
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].EvaluationTime < result[j].EvaluationTime
+	})
+	if query.Get("sortBy") == "tenantId" {
+		sort.SliceStable(result, func(i, j int) bool {
+			if result[i].TenantId == "" || result[j].TenantId == "" {
+				return result[j].TenantId == "" && result[i].TenantId != ""
+			}
+			return result[i].TenantId < result[j].TenantId
+		})
+	}
+	return result
+}
+
+// filter supports the query parameters used by camunda.HistoryFilter; the result is sorted by end time
+func (this *fakeCamunda) filter(query url.Values) (result []camunda.HistoricProcessInstance) {
+	var before time.Time
+	if query.Get("finishedBefore") != "" {
+		before, _ = time.Parse(camunda.CamundaTimeFormat, query.Get("finishedBefore"))
+	}
+	for _, instance := range this.instances {
+		endTime, _ := time.Parse(camunda.CamundaTimeFormat, instance.EndTime)
+		if !before.IsZero() && endTime.After(before) {
+			continue
+		}
+		if key := query.Get("processDefinitionKey"); key != "" && instance.ProcessDefinitionKey != key {
+			continue
+		}
+		if notIn := query.Get("processDefinitionKeyNotIn"); notIn != "" && contains(strings.Split(notIn, ","), instance.ProcessDefinitionKey) {
+			continue
+		}
+		if tenants := query.Get("tenantIdIn"); tenants != "" && !contains(strings.Split(tenants, ","), instance.TenantId) {
+			continue
+		}
+		if ids := query.Get("processInstanceIds"); ids != "" && !contains(strings.Split(ids, ","), instance.Id) {
+			continue
+		}
+		if query.Get("withoutTenantId") == "true" && instance.TenantId != "" {
+			continue
+		}
+		result = append(result, instance)
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].EndTime < result[j].EndTime
+	})
+	if query.Get("sortBy") == "tenantId" {
+		//like postgres, instances without tenant are sorted last
+		sort.SliceStable(result, func(i, j int) bool {
+			if result[i].TenantId == "" || result[j].TenantId == "" {
+				return result[j].TenantId == "" && result[i].TenantId != ""
+			}
+			return result[i].TenantId < result[j].TenantId
+		})
+	}
+	return result
+}
+
+func contains(list []string, value string) bool {
+	for _, element := range list {
+		if element == value {
+			return true
+		}
+	}
+	return false
+}
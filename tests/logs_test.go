@@ -0,0 +1,78 @@
+/*
+ * Copyright 2020 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tests
+
+import (
+	"context"
+	"github.com/SENERGY-Platform/process-history-cleanup/pkg"
+	"github.com/SENERGY-Platform/process-history-cleanup/pkg/configuration"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHistoricBatchCleanup(t *testing.T) {
+	engine := newFakeCamunda()
+	for i := 0; i < 11; i++ {
+		engine.addBatch("old_"+strconv.Itoa(i), 48*time.Hour+time.Duration(i)*time.Minute)
+	}
+	for i := 0; i < 3; i++ {
+		engine.addBatch("young_"+strconv.Itoa(i), time.Hour)
+	}
+	server := httptest.NewServer(engine)
+	defer server.Close()
+
+	config := &configuration.ConfigStruct{
+		EngineUrl:           server.URL,
+		MaxAge:              "10m",
+		BatchSize:           4,
+		Location:            "Europe/Berlin",
+		HistoricBatchMaxAge: "24h",
+		DryRun:              true,
+	}
+
+	t.Run("dry-run", func(t *testing.T) {
+		err := pkg.RunCleanup(context.Background(), config, nil)
+		if err != nil {
+			t.Error(err)
+		}
+		if engine.batchCount() != 14 {
+			t.Error(engine.batchCount())
+		}
+	})
+
+	t.Run("remove", func(t *testing.T) {
+		config.DryRun = false
+		err := pkg.RunCleanup(context.Background(), config, nil)
+		if err != nil {
+			t.Error(err)
+		}
+		if engine.batchCount() != 3 {
+			t.Error(engine.batchCount())
+		}
+	})
+
+	t.Run("job log with rest backend", func(t *testing.T) {
+		config.JobLogMaxAge = "24h"
+		err := pkg.RunCleanup(context.Background(), config, nil)
+		if err == nil || !strings.Contains(err.Error(), "use the postgres backend") {
+			t.Error(err)
+		}
+	})
+}
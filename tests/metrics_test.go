@@ -0,0 +1,127 @@
+/*
+ * Copyright 2020 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tests
+
+import (
+	"context"
+	"errors"
+	"github.com/SENERGY-Platform/process-history-cleanup/pkg"
+	"github.com/SENERGY-Platform/process-history-cleanup/pkg/configuration"
+	"github.com/SENERGY-Platform/process-history-cleanup/pkg/metrics"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// scrape returns the sample values of the metrics endpoint by metric name and labels,
+// e.g. process_history_cleanup_deleted_instances_total{process_definition_key="a",tenant_id=""}
+func scrape(t *testing.T, url string) map[string]float64 {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := map[string]float64{}
+	for _, line := range strings.Split(string(body), "\n") {
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		index := strings.LastIndex(line, " ")
+		value, err := strconv.ParseFloat(line[index+1:], 64)
+		if err != nil {
+			t.Fatal(line, err)
+		}
+		result[line[:index]] = value
+	}
+	return result
+}
+
+func TestMetricsAfterRun(t *testing.T) {
+	engine := newFakeCamunda()
+	for i := 0; i < 5; i++ {
+		engine.addFinished("a_"+strconv.Itoa(i), "a", "", time.Hour)
+		engine.addFinished("b_"+strconv.Itoa(i), "b", "owner", time.Hour)
+	}
+	engine.addFinished("b_young", "b", "owner", time.Second)
+	engine.addDecision("decision_old", "d", time.Hour)
+	engine.failIds["b_1"] = true
+	engine.failIds["b_3"] = true
+	server := httptest.NewServer(engine)
+	defer server.Close()
+
+	m := metrics.New()
+	metricsServer := httptest.NewServer(m.Handler())
+	defer metricsServer.Close()
+
+	config := &configuration.ConfigStruct{
+		EngineUrl:      server.URL,
+		MaxAge:         "10m",
+		DecisionMaxAge: "10m",
+		BatchSize:      3,
+		Location:       "Europe/Berlin",
+	}
+
+	check := func(values map[string]float64, name string, expected float64) {
+		t.Helper()
+		if values[name] != expected {
+			t.Error(name, values[name], expected)
+		}
+	}
+
+	t.Run("with failed deletions", func(t *testing.T) {
+		err := pkg.RunCleanup(context.Background(), config, m)
+		if !errors.Is(err, pkg.ErrDeleteFailed) {
+			t.Error(err)
+		}
+		values := scrape(t, metricsServer.URL)
+		check(values, `process_history_cleanup_deleted_instances_total{process_definition_key="a",tenant_id=""}`, 5)
+		check(values, `process_history_cleanup_deleted_instances_total{process_definition_key="b",tenant_id="owner"}`, 3)
+		check(values, `process_history_cleanup_failed_deletions_total{process_definition_key="b",tenant_id="owner"}`, 2)
+		check(values, `process_history_cleanup_deleted_decision_instances_total{decision_definition_key="d",tenant_id=""}`, 1)
+		check(values, `process_history_cleanup_backlog_instances`, 2)
+		check(values, `process_history_cleanup_run_in_progress`, 0)
+		check(values, `process_history_cleanup_last_successful_run_timestamp_seconds`, 0)
+	})
+
+	t.Run("successful", func(t *testing.T) {
+		engine.mux.Lock()
+		engine.failIds = map[string]bool{}
+		engine.mux.Unlock()
+		start := time.Now()
+		err := pkg.RunCleanup(context.Background(), config, m)
+		if err != nil {
+			t.Error(err)
+		}
+		values := scrape(t, metricsServer.URL)
+		check(values, `process_history_cleanup_deleted_instances_total{process_definition_key="b",tenant_id="owner"}`, 5)
+		check(values, `process_history_cleanup_failed_deletions_total{process_definition_key="b",tenant_id="owner"}`, 2)
+		check(values, `process_history_cleanup_backlog_instances`, 0)
+		lastSuccessfulRun := values[`process_history_cleanup_last_successful_run_timestamp_seconds`]
+		if lastSuccessfulRun < float64(start.Unix()) {
+			t.Error(lastSuccessfulRun, start.Unix())
+		}
+	})
+}
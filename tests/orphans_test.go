@@ -0,0 +1,102 @@
+/*
+ * Copyright 2020 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tests
+
+import (
+	"context"
+	"github.com/SENERGY-Platform/process-history-cleanup/pkg"
+	"github.com/SENERGY-Platform/process-history-cleanup/pkg/configuration"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestOrphanCleanup(t *testing.T) {
+	engine := newFakeCamunda()
+	for i := 0; i < 5; i++ {
+		id := "instance_" + strconv.Itoa(i)
+		engine.addFinished(id, "test", "", time.Second)
+		engine.addVariable("var_"+id, id)
+	}
+	for i := 0; i < 23; i++ {
+		engine.addVariable("orphan_"+strconv.Itoa(i), "removed_"+strconv.Itoa(i))
+	}
+	engine.addVariable("standalone", "")
+	server := httptest.NewServer(engine)
+	defer server.Close()
+
+	config := &configuration.ConfigStruct{
+		EngineUrl:     server.URL,
+		MaxAge:        "10m",
+		BatchSize:     4,
+		Location:      "Europe/Berlin",
+		OrphanCleanup: true,
+		DryRun:        true,
+	}
+
+	t.Run("dry-run", func(t *testing.T) {
+		err := pkg.RunCleanup(context.Background(), config, nil)
+		if err != nil {
+			t.Error(err)
+		}
+		if engine.variableCount() != 29 {
+			t.Error(engine.variableCount())
+		}
+	})
+
+	t.Run("remove", func(t *testing.T) {
+		config.DryRun = false
+		err := pkg.RunCleanup(context.Background(), config, nil)
+		if err != nil {
+			t.Error(err)
+		}
+		if engine.variableCount() != 6 {
+			t.Error(engine.variableCount())
+		}
+		if engine.count() != 5 {
+			t.Error(engine.count())
+		}
+	})
+}
+
+func TestOrphanCleanupLargeBatch(t *testing.T) {
+	engine := newFakeCamunda()
+	for i := 0; i < 400; i++ {
+		id := "0c7d9e62-4f1a-11ef-9a6b-0242ac12" + strconv.Itoa(1000+i)
+		if i%2 == 0 {
+			engine.addFinished(id, "test", "", time.Second)
+		}
+		engine.addVariable("var_"+strconv.Itoa(i), id)
+	}
+	server := httptest.NewServer(engine)
+	defer server.Close()
+
+	err := pkg.RunCleanup(context.Background(), &configuration.ConfigStruct{
+		EngineUrl:     server.URL,
+		MaxAge:        "10m",
+		BatchSize:     400,
+		Location:      "Europe/Berlin",
+		OrphanCleanup: true,
+	}, nil)
+	if err != nil {
+		t.Error(err)
+	}
+	if engine.variableCount() != 200 {
+		t.Error(engine.variableCount())
+	}
+}
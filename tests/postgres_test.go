@@ -0,0 +1,163 @@
+/*
+ * Copyright 2020 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tests
+
+import (
+	"context"
+	"database/sql"
+	"github.com/SENERGY-Platform/process-history-cleanup/pkg"
+	"github.com/SENERGY-Platform/process-history-cleanup/pkg/configuration"
+	"github.com/SENERGY-Platform/process-history-cleanup/tests/docker"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPostgresCleanup(t *testing.T) {
+	t.Run("single", testPostgresCleanup(2, 3, pkg.DeleteStrategySingle, false))
+	t.Run("batch", testPostgresCleanup(2, 3, pkg.DeleteStrategyBatch, false))
+	t.Run("local filter", testPostgresCleanup(2, 3, pkg.DeleteStrategyBatch, true))
+}
+
+func testPostgresCleanup(batchSize int, deleteCount int, deleteStrategy string, filterLocally bool) func(t *testing.T) {
+	return func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		wg := &sync.WaitGroup{}
+		defer wg.Wait()
+		defer cancel()
+
+		pgConnStr, camundaPgIp, _, err := docker.PostgresWithNetwork(ctx, wg, "camunda")
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		camundaUrl, err := docker.Camunda(ctx, wg, camundaPgIp, "5432")
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		processId := ""
+		t.Run("create process", testCreateProcess(camundaUrl, &processId))
+		t.Run("start process n times", testStartProcesses(camundaUrl, processId, deleteCount))
+		t.Run("check n created instances", testRunCheck(camundaUrl, deleteCount))
+
+		runCleanup := func(maxAge string) func(t *testing.T) {
+			return func(t *testing.T) {
+				err := pkg.RunCleanup(context.Background(), &configuration.ConfigStruct{
+					Backend:          pkg.BackendPostgres,
+					PostgresConnStr:  pgConnStr,
+					PostgresTimeZone: "UTC",
+					MaxAge:           maxAge,
+					BatchSize:        batchSize,
+					FilterLocally:    filterLocally,
+					DeleteStrategy:   deleteStrategy,
+					Location:         "Europe/Berlin",
+				}, nil)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}
+
+		t.Run("run cleanup 10m", runCleanup("10m"))
+		t.Run("check after 10m cleanup", testRunCheck(camundaUrl, deleteCount))
+
+		time.Sleep(2 * time.Second)
+		t.Run("start process 1 times", testStartProcesses(camundaUrl, processId, 1))
+		time.Sleep(1 * time.Second)
+
+		t.Run("run cleanup 2s", runCleanup("2s"))
+		t.Run("check 2s cleanup", testRunCheck(camundaUrl, 1))
+		t.Run("check dependent history removed", testCheckActivityInstanceCount(pgConnStr, 1))
+	}
+}
+
+// every blank process instance has exactly one activity instance for its start event
+func testCheckActivityInstanceCount(pgConnStr string, expected int) func(t *testing.T) {
+	return func(t *testing.T) {
+		db, err := sql.Open("postgres", pgConnStr)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer db.Close()
+		var count int
+		err = db.QueryRow("SELECT COUNT(*) FROM ACT_HI_ACTINST").Scan(&count)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if count != expected {
+			t.Error(expected, count)
+		}
+	}
+}
+
+func TestPostgresOrphanCleanup(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	wg := &sync.WaitGroup{}
+	defer wg.Wait()
+	defer cancel()
+
+	pgConnStr, camundaPgIp, _, err := docker.PostgresWithNetwork(ctx, wg, "camunda")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	camundaUrl, err := docker.Camunda(ctx, wg, camundaPgIp, "5432")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	processId := ""
+	t.Run("create process", testCreateProcess(camundaUrl, &processId))
+	t.Run("start process 2 times", testStartProcesses(camundaUrl, processId, 2))
+
+	t.Run("orphan one instance by sql", func(t *testing.T) {
+		db, err := sql.Open("postgres", pgConnStr)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer db.Close()
+		_, err = db.Exec("DELETE FROM ACT_HI_PROCINST WHERE ID_ = (SELECT MIN(ID_) FROM ACT_HI_PROCINST)")
+		if err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("run orphan cleanup", func(t *testing.T) {
+		err := pkg.RunCleanup(context.Background(), &configuration.ConfigStruct{
+			Backend:          pkg.BackendPostgres,
+			PostgresConnStr:  pgConnStr,
+			PostgresTimeZone: "UTC",
+			MaxAge:           "1000h",
+			BatchSize:        10,
+			Location:         "Europe/Berlin",
+			OrphanCleanup:    true,
+		}, nil)
+		if err != nil {
+			t.Error(err)
+		}
+	})
+	t.Run("check orphaned activity instance removed", testCheckActivityInstanceCount(pgConnStr, 1))
+}
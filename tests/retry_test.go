@@ -0,0 +1,147 @@
+/*
+ * Copyright 2020 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tests
+
+import (
+	"context"
+	"errors"
+	"github.com/SENERGY-Platform/process-history-cleanup/pkg/camunda"
+	"github.com/SENERGY-Platform/process-history-cleanup/pkg/configuration"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+)
+
+func TestRetry(t *testing.T) {
+	fake := newFakeCamunda()
+	fake.addFinished("a", "key", "", 0)
+	status := 0
+	body := ""
+	failures := atomic.Int64{}
+	requests := atomic.Int64{}
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		requests.Add(1)
+		if failures.Add(-1) >= 0 {
+			http.Error(writer, body, status)
+			return
+		}
+		fake.ServeHTTP(writer, request)
+	}))
+	defer server.Close()
+
+	engine := camunda.New(&configuration.ConfigStruct{
+		EngineUrl:            server.URL,
+		EngineMaxRetries:     3,
+		EngineRetryBaseDelay: "1ms",
+		EngineRetryMaxDelay:  "10ms",
+		Location:             "Europe/Berlin",
+	})
+
+	run := func(name string, setStatus int, setBody string, setFailures int64, expectedRequests int64, check func(err error) bool) {
+		t.Run(name, func(t *testing.T) {
+			status = setStatus
+			body = setBody
+			failures.Store(setFailures)
+			requests.Store(0)
+			_, err := engine.ListHistoryCount(context.Background(), true)
+			if !check(err) {
+				t.Error(err)
+			}
+			if requests.Load() != expectedRequests {
+				t.Error(requests.Load(), expectedRequests)
+			}
+		})
+	}
+
+	run("503 recovers", http.StatusServiceUnavailable, "", 2, 3, func(err error) bool {
+		return err == nil
+	})
+	run("optimistic locking", http.StatusInternalServerError, `{"type":"OptimisticLockingException"}`, 1, 2, func(err error) bool {
+		return err == nil
+	})
+	run("500 not retried", http.StatusInternalServerError, `{"type":"ProcessEngineException"}`, 1, 1, func(err error) bool {
+		return errors.Is(err, camunda.ErrUnexpectedResponse) && !errors.Is(err, camunda.ErrRetriesExhausted)
+	})
+	run("400 not retried", http.StatusBadRequest, "", 1, 1, func(err error) bool {
+		return errors.Is(err, camunda.ErrUnexpectedResponse) && !errors.Is(err, camunda.ErrRetriesExhausted)
+	})
+	run("exhausted", http.StatusBadGateway, "", 10, 4, func(err error) bool {
+		return errors.Is(err, camunda.ErrRetriesExhausted) && !errors.Is(err, camunda.ErrUnexpectedResponse)
+	})
+
+	t.Run("post 503 not retried", func(t *testing.T) {
+		status = http.StatusServiceUnavailable
+		failures.Store(1)
+		requests.Store(0)
+		err := engine.RemoveHistoricDecisionInstances(context.Background(), []string{"a"})
+		if !errors.Is(err, camunda.ErrUnexpectedResponse) || errors.Is(err, camunda.ErrRetriesExhausted) {
+			t.Error(err)
+		}
+		if requests.Load() != 1 {
+			t.Error(requests.Load())
+		}
+	})
+
+	t.Run("delete unknown id", func(t *testing.T) {
+		failures.Store(0)
+		err := engine.RemoveProcessInstanceHistory(context.Background(), "unknown")
+		if err != nil {
+			t.Error(err)
+		}
+	})
+}
+
+func TestRetryOnlyNetworkErrors(t *testing.T) {
+	requests := atomic.Int64{}
+	tlsServer := httptest.NewTLSServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		requests.Add(1)
+	}))
+	defer tlsServer.Close()
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	run := func(name string, config configuration.ConfigStruct, retried bool) {
+		t.Run(name, func(t *testing.T) {
+			config.EngineMaxRetries = 2
+			config.EngineRetryBaseDelay = "1ms"
+			config.EngineRetryMaxDelay = "10ms"
+			config.Location = "Europe/Berlin"
+			_, err := camunda.New(&config).ListHistoryCount(context.Background(), true)
+			if err == nil || errors.Is(err, camunda.ErrRetriesExhausted) != retried {
+				t.Error(err)
+			}
+		})
+	}
+	run("connection refused", configuration.ConfigStruct{EngineUrl: closed.URL}, true)
+	run("unknown certificate authority", configuration.ConfigStruct{EngineUrl: tlsServer.URL}, false)
+	run("missing secret file", configuration.ConfigStruct{
+		EngineUrl:       tlsServer.URL,
+		EngineAuth:      camunda.AuthBearer,
+		EngineTokenFile: filepath.Join(t.TempDir(), "missing"),
+	}, false)
+	run("unreachable token url", configuration.ConfigStruct{
+		EngineUrl:      tlsServer.URL,
+		EngineAuth:     camunda.AuthOAuth2,
+		EngineTokenUrl: closed.URL,
+		EngineClientId: "client",
+	}, false)
+	if requests.Load() != 0 {
+		t.Error(requests.Load())
+	}
+}
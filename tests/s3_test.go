@@ -0,0 +1,211 @@
+/*
+ * Copyright 2020 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tests
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"github.com/SENERGY-Platform/process-history-cleanup/pkg/archive"
+	"github.com/SENERGY-Platform/process-history-cleanup/pkg/camunda"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestS3Archive(t *testing.T) {
+	store := &fakeS3{objects: map[string][]byte{}}
+	server := httptest.NewServer(store)
+	defer server.Close()
+
+	sink, err := archive.NewS3Sink(archive.S3Config{
+		Endpoint:  server.URL,
+		Bucket:    "history",
+		AccessKey: "access",
+		SecretKey: "secret",
+		Prefix:    "archive",
+		Gzip:      true,
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	records := []archive.Record{
+		createS3Record("1", "owner1", "device_command", "2024-03-04T10:00:00.000+0100"),
+		createS3Record("2", "owner1", "device_command", "2024-03-04T11:00:00.000+0100"),
+		createS3Record("3", "owner2", "device_command", "2024-03-04T11:00:00.000+0100"),
+		createS3Record("4", "owner1", "billing", "2024-03-05T11:00:00.000+0100"),
+	}
+	err = sink.Write(context.Background(), records)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if len(store.objects) != 3 {
+		t.Error(len(store.objects), store.keys())
+		return
+	}
+	expectedPrefixes := map[string]int{
+		"/history/archive/tenant=owner1/process_definition_key=device_command/end_date=2024-03-04/": 2,
+		"/history/archive/tenant=owner2/process_definition_key=device_command/end_date=2024-03-04/": 1,
+		"/history/archive/tenant=owner1/process_definition_key=billing/end_date=2024-03-05/":        1,
+	}
+	for key, content := range store.objects {
+		found := false
+		for prefix, count := range expectedPrefixes {
+			if strings.HasPrefix(key, prefix) {
+				found = true
+				decoded, err := decodeS3Object(content)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if len(decoded) != count {
+					t.Error(key, len(decoded), count)
+				}
+			}
+		}
+		if !found {
+			t.Error("unexpected key", key)
+		}
+	}
+}
+
+func TestS3ArchiveVerification(t *testing.T) {
+	store := &fakeS3{objects: map[string][]byte{}, corrupt: true}
+	server := httptest.NewServer(store)
+	defer server.Close()
+
+	sink, err := archive.NewS3Sink(archive.S3Config{Endpoint: server.URL, Bucket: "history", AccessKey: "access", SecretKey: "secret"})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	err = sink.Write(context.Background(), []archive.Record{createS3Record("1", "owner1", "test", "2024-03-04T10:00:00.000+0100")})
+	if !errors.Is(err, archive.ErrUploadVerification) {
+		t.Error(err)
+	}
+}
+
+func TestS3ArchiveCanceled(t *testing.T) {
+	block := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		<-block
+	}))
+	defer server.Close()
+	defer close(block)
+
+	sink, err := archive.NewS3Sink(archive.S3Config{Endpoint: server.URL, Bucket: "history", AccessKey: "access", SecretKey: "secret"})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	err = sink.Write(ctx, []archive.Record{createS3Record("1", "owner1", "test", "2024-03-04T10:00:00.000+0100")})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Error(err)
+	}
+}
+
+func createS3Record(id string, tenant string, key string, endTime string) archive.Record {
+	return archive.Record{
+		Instance: camunda.HistoricProcessInstance{Id: id, TenantId: tenant, ProcessDefinitionKey: key, EndTime: endTime},
+	}
+}
+
+func decodeS3Object(content []byte) (result []archive.Record, err error) {
+	reader, err := gzip.NewReader(bytes.NewReader(content))
+	if err != nil {
+		return result, err
+	}
+	decoder := json.NewDecoder(reader)
+	for {
+		record := archive.Record{}
+		err = decoder.Decode(&record)
+		if errors.Is(err, io.EOF) {
+			return result, nil
+		}
+		if err != nil {
+			return result, err
+		}
+		result = append(result, record)
+	}
+}
+
+// fakeS3 is an in memory stand-in for the s3 object api
+// with corrupt set, stored objects lose their last byte
+type fakeS3 struct {
+	mux     sync.Mutex
+	objects map[string][]byte
+	corrupt bool
+}
+
+func (this *fakeS3) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	if !strings.HasPrefix(request.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=access/") {
+		http.Error(writer, "missing signature", http.StatusForbidden)
+		return
+	}
+	key, _ := url.PathUnescape(request.URL.EscapedPath())
+	switch request.Method {
+	case http.MethodPut:
+		body, _ := io.ReadAll(request.Body)
+		sum := md5.Sum(body)
+		if request.Header.Get("Content-MD5") != base64.StdEncoding.EncodeToString(sum[:]) {
+			http.Error(writer, "bad digest", http.StatusBadRequest)
+			return
+		}
+		if this.corrupt {
+			body = body[:len(body)-1]
+		}
+		this.objects[key] = body
+		//like sse-kms buckets, the etag is not the md5 of the body
+		writer.Header().Set("ETag", "\"kms-"+strconv.Itoa(len(this.objects))+"\"")
+		writer.WriteHeader(http.StatusOK)
+	case http.MethodHead:
+		object, ok := this.objects[key]
+		if !ok {
+			writer.WriteHeader(http.StatusNotFound)
+			return
+		}
+		writer.Header().Set("Content-Length", strconv.Itoa(len(object)))
+		writer.WriteHeader(http.StatusOK)
+	default:
+		writer.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (this *fakeS3) keys() (result []string) {
+	for key := range this.objects {
+		result = append(result, key)
+	}
+	return result
+}
@@ -0,0 +1,126 @@
+/*
+ * Copyright 2020 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tests
+
+import (
+	"github.com/SENERGY-Platform/process-history-cleanup/pkg/configuration"
+	"github.com/SENERGY-Platform/process-history-cleanup/pkg/scheduler"
+	"testing"
+	"time"
+)
+
+func TestCronSchedule(t *testing.T) {
+	schedule, err := scheduler.FromConfig(&configuration.ConfigStruct{
+		Schedule: "0 3 * * *",
+		Location: "Europe/Berlin",
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+
+	next := schedule.Next(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC))
+	expected := time.Date(2024, 3, 2, 3, 0, 0, 0, berlin)
+	if !next.Equal(expected) {
+		t.Error(expected, next)
+	}
+
+	//skip missed runs
+	next = scheduler.NextAfter(schedule, time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC), time.Date(2024, 3, 5, 12, 0, 0, 0, time.UTC))
+	expected = time.Date(2024, 3, 6, 3, 0, 0, 0, berlin)
+	if !next.Equal(expected) {
+		t.Error(expected, next)
+	}
+
+	period := scheduler.Period(schedule, time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC))
+	if period != 24*time.Hour {
+		t.Error(period)
+	}
+}
+
+func TestScheduleConfig(t *testing.T) {
+	schedule, err := scheduler.FromConfig(&configuration.ConfigStruct{Location: "Europe/Berlin"})
+	if err != nil || schedule != nil {
+		t.Error(schedule, err)
+	}
+	schedule, err = scheduler.FromConfig(&configuration.ConfigStruct{Interval: "-", Schedule: "-", Location: "Europe/Berlin"})
+	if err != nil || schedule != nil {
+		t.Error(schedule, err)
+	}
+	_, err = scheduler.FromConfig(&configuration.ConfigStruct{Interval: "1h", Schedule: "0 3 * * *", Location: "Europe/Berlin"})
+	if err == nil {
+		t.Error("expect error for interval and schedule")
+	}
+	schedule, err = scheduler.FromConfig(&configuration.ConfigStruct{Interval: "1h", Location: "Europe/Berlin"})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	start := time.Now()
+	if next := schedule.Next(start); next.Sub(start) != time.Hour {
+		t.Error(next)
+	}
+}
+
+func TestMaintenanceWindows(t *testing.T) {
+	windows, err := scheduler.WindowsFromConfig(&configuration.ConfigStruct{
+		Location: "Europe/Berlin",
+		MaintenanceWindows: []configuration.MaintenanceWindow{
+			{Weekdays: []string{"mon", "tue", "wed", "thu", "fri"}, Start: "22:00", End: "05:00"},
+		},
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+
+	//2024-03-04 is a monday
+	cases := []struct {
+		time time.Time
+		open bool
+	}{
+		{time: time.Date(2024, 3, 4, 21, 59, 0, 0, berlin), open: false},
+		{time: time.Date(2024, 3, 4, 22, 0, 0, 0, berlin), open: true},
+		{time: time.Date(2024, 3, 5, 4, 59, 0, 0, berlin), open: true},
+		{time: time.Date(2024, 3, 5, 5, 0, 0, 0, berlin), open: false},
+		{time: time.Date(2024, 3, 4, 2, 0, 0, 0, berlin), open: false}, //window of sunday
+		{time: time.Date(2024, 3, 9, 2, 0, 0, 0, berlin), open: true},  //window of friday
+		{time: time.Date(2024, 3, 9, 23, 0, 0, 0, berlin), open: false},
+	}
+	for _, c := range cases {
+		if windows.Open(c.time) != c.open {
+			t.Error(c.time, c.open)
+		}
+	}
+
+	next := windows.NextOpen(time.Date(2024, 3, 9, 12, 0, 0, 0, berlin))
+	expected := time.Date(2024, 3, 11, 22, 0, 0, 0, berlin)
+	if !next.Equal(expected) {
+		t.Error(expected, next)
+	}
+
+	always, err := scheduler.WindowsFromConfig(&configuration.ConfigStruct{Location: "Europe/Berlin"})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if !always.Open(time.Now()) {
+		t.Error("expect open without configured windows")
+	}
+}
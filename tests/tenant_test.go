@@ -0,0 +1,78 @@
+/*
+ * Copyright 2020 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tests
+
+import (
+	"context"
+	"github.com/SENERGY-Platform/process-history-cleanup/pkg"
+	"github.com/SENERGY-Platform/process-history-cleanup/pkg/configuration"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// the fake engine has no deployments, tenants are only known by their history
+// exempt tenants are never listed, they are excluded by the tenantIdIn queries
+func TestTenantRulesWithoutDeployments(t *testing.T) {
+	for _, filterLocally := range []bool{false, true} {
+		t.Run("filter locally "+strconv.FormatBool(filterLocally), func(t *testing.T) {
+			engine := newFakeCamunda()
+			for i := 0; i < 5; i++ {
+				engine.addFinished("exempt_"+strconv.Itoa(i), "test", "exempt", 10*time.Hour+time.Duration(i)*time.Minute)
+			}
+			for i := 0; i < 3; i++ {
+				engine.addFinished("tenant_"+strconv.Itoa(i)+"_old", "test", "tenant_"+strconv.Itoa(i), 5*time.Hour)
+			}
+			engine.addFinished("ruled_old", "test", "ruled", 48*time.Hour)
+			engine.addFinished("ruled_young", "test", "ruled", 2*time.Hour)
+			engine.addFinished("other_old", "test", "other", 3*time.Hour)
+			engine.addFinished("other_young", "test", "other", time.Minute)
+			engine.addFinished("without_tenant_old", "test", "", 11*time.Hour)
+			server := httptest.NewServer(engine)
+			defer server.Close()
+
+			err := pkg.RunCleanup(context.Background(), &configuration.ConfigStruct{
+				EngineUrl:            server.URL,
+				MaxAge:               "1h",
+				TenantRetentionRules: []configuration.TenantRetentionRule{{TenantId: "ruled", MaxAge: "1d"}},
+				ExemptTenants:        []string{"exempt"},
+				BatchSize:            2,
+				FilterLocally:        filterLocally,
+				Location:             "Europe/Berlin",
+			}, nil)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			remaining := []string{}
+			for _, instance := range engine.instances {
+				remaining = append(remaining, instance.Id)
+			}
+			sort.Strings(remaining)
+			expected := "exempt_0,exempt_1,exempt_2,exempt_3,exempt_4,other_young,ruled_young"
+			if strings.Join(remaining, ",") != expected {
+				t.Error(remaining)
+			}
+			if engine.listed["exempt"] != 0 {
+				t.Error("exempt tenant listed", engine.listed["exempt"])
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+/*
+ * Copyright 2020 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tests
+
+import (
+	"context"
+	"github.com/SENERGY-Platform/process-history-cleanup/pkg/camunda"
+	"github.com/SENERGY-Platform/process-history-cleanup/pkg/configuration"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRateLimit(t *testing.T) {
+	server := httptest.NewServer(newFakeCamunda())
+	defer server.Close()
+
+	engine := camunda.New(&configuration.ConfigStruct{
+		EngineUrl:       server.URL,
+		EngineRateLimit: 20,
+		Location:        "Europe/Berlin",
+	})
+	start := time.Now()
+	for i := 0; i < 11; i++ {
+		_, err := engine.ListHistoryCount(context.Background(), true)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+	}
+	if duration := time.Since(start); duration < 450*time.Millisecond {
+		t.Error("expect rate limit", duration)
+	}
+}
+
+func TestAdaptiveThrottling(t *testing.T) {
+	fake := newFakeCamunda()
+	failures := atomic.Int64{}
+	failures.Store(3)
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if failures.Add(-1) >= 0 {
+			http.Error(writer, "overloaded", http.StatusServiceUnavailable)
+			return
+		}
+		fake.ServeHTTP(writer, request)
+	}))
+	defer server.Close()
+
+	engine := camunda.New(&configuration.ConfigStruct{
+		EngineUrl:                server.URL,
+		EngineRateLimit:          100,
+		EngineMinRateLimit:       5,
+		EngineAdaptiveThrottling: true,
+		EngineLatencyThreshold:   "1s",
+		Location:                 "Europe/Berlin",
+	})
+	limits := []float64{}
+	engine.OnRateLimitChange(func(limit float64) {
+		limits = append(limits, limit)
+	})
+	for i := 0; i < 3; i++ {
+		engine.ListHistoryCount(context.Background(), true)
+	}
+	if limits[len(limits)-1] != 12.5 {
+		t.Error(limits)
+		return
+	}
+	for i := 0; i < 20; i++ {
+		_, err := engine.ListHistoryCount(context.Background(), true)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+	}
+	if limits[len(limits)-1] != 100 {
+		t.Error(limits)
+	}
+}
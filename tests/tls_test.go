@@ -0,0 +1,133 @@
+/*
+ * Copyright 2020 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tests
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"github.com/SENERGY-Platform/process-history-cleanup/pkg/camunda"
+	"github.com/SENERGY-Platform/process-history-cleanup/pkg/configuration"
+	"math/big"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestEngineTls(t *testing.T) {
+	dir := t.TempDir()
+	clientCert, clientCertFile, clientKeyFile := createClientCert(t, dir)
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCert)
+
+	server := httptest.NewUnstartedServer(newFakeCamunda())
+	server.TLS = &tls.Config{
+		ClientAuth: tls.VerifyClientCertIfGiven,
+		ClientCAs:  clientCAs,
+		MaxVersion: tls.VersionTLS12,
+	}
+	server.StartTLS()
+	defer server.Close()
+
+	mtlsServer := httptest.NewUnstartedServer(newFakeCamunda())
+	mtlsServer.TLS = &tls.Config{
+		ClientAuth: tls.RequireAndVerifyClientCert,
+		ClientCAs:  clientCAs,
+	}
+	mtlsServer.StartTLS()
+	defer mtlsServer.Close()
+
+	caFile := filepath.Join(dir, "ca.pem")
+	err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	run := func(name string, url string, config configuration.ConfigStruct, expectSuccess bool) {
+		t.Run(name, func(t *testing.T) {
+			config.EngineUrl = url
+			config.Location = "Europe/Berlin"
+			_, err := camunda.New(&config).ListHistoryCount(context.Background(), true)
+			if expectSuccess && err != nil {
+				t.Error(err)
+			}
+			if !expectSuccess && err == nil {
+				t.Error("expect error")
+			}
+		})
+	}
+
+	run("unknown ca", server.URL, configuration.ConfigStruct{}, false)
+	run("ca file", server.URL, configuration.ConfigStruct{EngineCaFile: caFile}, true)
+	run("insecure skip verify", server.URL, configuration.ConfigStruct{EngineTlsInsecureSkipVerify: true}, true)
+	run("min version", server.URL, configuration.ConfigStruct{EngineCaFile: caFile, EngineTlsMinVersion: "1.3"}, false)
+	run("invalid min version", server.URL, configuration.ConfigStruct{EngineCaFile: caFile, EngineTlsMinVersion: "1.4"}, false)
+	run("missing client cert", mtlsServer.URL, configuration.ConfigStruct{EngineCaFile: caFile}, false)
+	run("client cert", mtlsServer.URL, configuration.ConfigStruct{
+		EngineCaFile:         caFile,
+		EngineClientCertFile: clientCertFile,
+		EngineClientKeyFile:  clientKeyFile,
+	}, true)
+}
+
+// createClientCert writes a self-signed client certificate and its key as pem files to dir
+func createClientCert(t *testing.T, dir string) (cert *x509.Certificate, certFile string, keyFile string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "process-history-cleanup"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err = x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile = filepath.Join(dir, "client.pem")
+	keyFile = filepath.Join(dir, "client-key.pem")
+	err = os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, certFile, keyFile
+}
@@ -0,0 +1,119 @@
+/*
+ * Copyright 2020 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tests
+
+import (
+	"context"
+	"errors"
+	"github.com/SENERGY-Platform/process-history-cleanup/pkg"
+	"github.com/SENERGY-Platform/process-history-cleanup/pkg/configuration"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConcurrentDelete(t *testing.T) {
+	engine := newFakeCamunda()
+	for i := 0; i < 50; i++ {
+		engine.addFinished("old_"+strconv.Itoa(i), "test", "owner", time.Hour+time.Duration(i)*time.Second)
+	}
+	for i := 0; i < 5; i++ {
+		engine.addFinished("young_"+strconv.Itoa(i), "test", "owner", time.Second)
+	}
+	engine.failIds["old_3"] = true
+	engine.failIds["old_42"] = true
+	server := httptest.NewServer(engine)
+	defer server.Close()
+
+	err := pkg.RunCleanup(context.Background(), &configuration.ConfigStruct{
+		EngineUrl:         server.URL,
+		MaxAge:            "10m",
+		BatchSize:         10,
+		DeleteConcurrency: 4,
+		Location:          "Europe/Berlin",
+	}, nil)
+	if !errors.Is(err, pkg.ErrDeleteFailed) {
+		t.Error(err)
+	}
+	//the failed instances are tried once and then skipped
+	if engine.count() != 7 {
+		t.Error(engine.count())
+	}
+	if engine.deletes != 50 {
+		t.Error(engine.deletes)
+	}
+	if engine.maxFlight < 2 || engine.maxFlight > 4 {
+		t.Error(engine.maxFlight)
+	}
+}
+
+func TestEveryDeletionFailed(t *testing.T) {
+	engine := newFakeCamunda()
+	for i := 0; i < 3; i++ {
+		engine.addFinished("old_"+strconv.Itoa(i), "test", "owner", time.Hour+time.Duration(i)*time.Second)
+		engine.failIds["old_"+strconv.Itoa(i)] = true
+	}
+	server := httptest.NewServer(engine)
+	defer server.Close()
+
+	err := pkg.RunCleanup(context.Background(), &configuration.ConfigStruct{
+		EngineUrl:         server.URL,
+		MaxAge:            "10m",
+		BatchSize:         10,
+		DeleteConcurrency: 2,
+		Location:          "Europe/Berlin",
+	}, nil)
+	if !errors.Is(err, pkg.ErrDeleteFailed) {
+		t.Error(err)
+		return
+	}
+	//every failure is reported once
+	if strings.Count(err.Error(), "old_1") != 1 || !strings.Contains(err.Error(), "3 failed deletions") {
+		t.Error(err)
+	}
+}
+
+func TestInterruptedCleanup(t *testing.T) {
+	engine := newFakeCamunda()
+	for i := 0; i < 200; i++ {
+		engine.addFinished("old_"+strconv.Itoa(i), "test", "owner", time.Hour+time.Duration(i)*time.Second)
+	}
+	server := httptest.NewServer(engine)
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	err := pkg.RunCleanup(ctx, &configuration.ConfigStruct{
+		EngineUrl:         server.URL,
+		MaxAge:            "10m",
+		BatchSize:         10,
+		DeleteConcurrency: 2,
+		Location:          "Europe/Berlin",
+	}, nil)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Error(err)
+	}
+	if engine.count() == 0 || engine.count() == 200 {
+		t.Error(engine.count())
+	}
+	//in-flight deletions are completed
+	if engine.deletes != 200-engine.count() {
+		t.Error(engine.deletes, engine.count())
+	}
+}